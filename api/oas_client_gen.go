@@ -88,12 +88,12 @@ func NewWebhookClient(opts ...ClientOption) (*WebhookClient, error) {
 // UserEvent invokes userEvent operation.
 //
 // Webhook sent when a user event occurs (created, updated, deleted).
-func (c *WebhookClient) UserEvent(ctx context.Context, targetURL string, request *WebhookEvent) (UserEventRes, error) {
+func (c *WebhookClient) UserEvent(ctx context.Context, targetURL string, request WebhookEvent) (UserEventRes, error) {
 	res, err := c.sendUserEvent(ctx, targetURL, request)
 	return res, err
 }
 
-func (c *WebhookClient) sendUserEvent(ctx context.Context, targetURL string, request *WebhookEvent) (res UserEventRes, err error) {
+func (c *WebhookClient) sendUserEvent(ctx context.Context, targetURL string, request WebhookEvent) (res UserEventRes, err error) {
 	otelAttrs := []attribute.KeyValue{
 		otelogen.OperationID("userEvent"),
 		otelogen.WebhookName("userEvent"),
@@ -158,3 +158,77 @@ func (c *WebhookClient) sendUserEvent(ctx context.Context, targetURL string, req
 
 	return result, nil
 }
+
+// UserEventBatch invokes userEventBatch operation.
+//
+// Several user events sent in one request. Each event is handled on its own and gets its own result.
+func (c *WebhookClient) UserEventBatch(ctx context.Context, targetURL string, request WebhookEventBatch) (UserEventBatchRes, error) {
+	res, err := c.sendUserEventBatch(ctx, targetURL, request)
+	return res, err
+}
+
+func (c *WebhookClient) sendUserEventBatch(ctx context.Context, targetURL string, request WebhookEventBatch) (res UserEventBatchRes, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("userEventBatch"),
+		otelogen.WebhookName("userEventBatch"),
+	}
+	otelAttrs = append(otelAttrs, c.cfg.Attributes...)
+
+	// Run stopwatch.
+	startTime := time.Now()
+	defer func() {
+		// Use floating point division here for higher precision (instead of Millisecond method).
+		elapsedDuration := time.Since(startTime)
+		c.duration.Record(ctx, float64(elapsedDuration)/float64(time.Millisecond), metric.WithAttributes(otelAttrs...))
+	}()
+
+	// Increment request counter.
+	c.requests.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+
+	// Start a span for this request.
+	ctx, span := c.cfg.Tracer.Start(ctx, UserEventBatchOperation,
+		trace.WithAttributes(otelAttrs...),
+		clientSpanKind,
+	)
+	// Track stage for error reporting.
+	var stage string
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, stage)
+			c.errors.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+		}
+		span.End()
+	}()
+
+	stage = "BuildURL"
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return res, errors.Wrap(err, "parse target URL")
+	}
+	trimTrailingSlashes(u)
+
+	stage = "EncodeRequest"
+	r, err := ht.NewRequest(ctx, "POST", u)
+	if err != nil {
+		return res, errors.Wrap(err, "create request")
+	}
+	if err := encodeUserEventBatchRequest(request, r); err != nil {
+		return res, errors.Wrap(err, "encode request")
+	}
+
+	stage = "SendRequest"
+	resp, err := c.cfg.Client.Do(r)
+	if err != nil {
+		return res, errors.Wrap(err, "do request")
+	}
+	defer resp.Body.Close()
+
+	stage = "DecodeResponse"
+	result, err := decodeUserEventBatchResponse(resp)
+	if err != nil {
+		return res, errors.Wrap(err, "decode response")
+	}
+
+	return result, nil
+}
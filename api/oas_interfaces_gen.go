@@ -1,6 +1,10 @@
 // Code generated by ogen, DO NOT EDIT.
 package api
 
+type UserEventBatchRes interface {
+	userEventBatchRes()
+}
+
 type UserEventRes interface {
 	userEventRes()
 }
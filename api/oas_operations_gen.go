@@ -6,5 +6,6 @@ package api
 type OperationName = string
 
 const (
-	UserEventOperation OperationName = "UserEvent"
+	UserEventOperation      OperationName = "UserEvent"
+	UserEventBatchOperation OperationName = "UserEventBatch"
 )
@@ -11,7 +11,21 @@ import (
 )
 
 func encodeUserEventRequest(
-	req *WebhookEvent,
+	req WebhookEvent,
+	r *http.Request,
+) error {
+	const contentType = "application/json"
+	e := new(jx.Encoder)
+	{
+		req.Encode(e)
+	}
+	encoded := e.Bytes()
+	ht.SetBody(r, bytes.NewReader(encoded), contentType)
+	return nil
+}
+
+func encodeUserEventBatchRequest(
+	req WebhookEventBatch,
 	r *http.Request,
 ) error {
 	const contentType = "application/json"
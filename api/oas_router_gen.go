@@ -19,6 +19,14 @@ func (s *WebhookServer) Handle(webhookName string, w http.ResponseWriter, r *htt
 			return false
 		}
 		return true
+	case "userEventBatch":
+		switch r.Method {
+		case "POST":
+			s.handleUserEventBatchRequest([0]string{}, false, w, r)
+		default:
+			return false
+		}
+		return true
 	default:
 		return false
 	}
@@ -38,6 +46,13 @@ func (s *WebhookServer) Handler(webhookName string) http.Handler {
 				s.notAllowed(w, r, "POST")
 			}
 		})
+	case "userEventBatch":
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// We know that webhook exists, so false means wrong method.
+			if !s.Handle(webhookName, w, r) {
+				s.notAllowed(w, r, "POST")
+			}
+		})
 	default:
 		return http.HandlerFunc(s.notFound)
 	}
@@ -3,9 +3,172 @@
 package api
 
 import (
-	"github.com/go-faster/jx"
+	"time"
 )
 
+// Ref: #/components/schemas/BatchEventResult
+type BatchEventResult struct {
+	// The ID of the event.
+	ID string `json:"id"`
+	// Whether the event was handled.
+	Success bool `json:"success"`
+	// Why the event was not handled.
+	Error OptString `json:"error"`
+}
+
+// GetID returns the value of ID.
+func (s *BatchEventResult) GetID() string {
+	return s.ID
+}
+
+// GetSuccess returns the value of Success.
+func (s *BatchEventResult) GetSuccess() bool {
+	return s.Success
+}
+
+// GetError returns the value of Error.
+func (s *BatchEventResult) GetError() OptString {
+	return s.Error
+}
+
+// SetID sets the value of ID.
+func (s *BatchEventResult) SetID(val string) {
+	s.ID = val
+}
+
+// SetSuccess sets the value of Success.
+func (s *BatchEventResult) SetSuccess(val bool) {
+	s.Success = val
+}
+
+// SetError sets the value of Error.
+func (s *BatchEventResult) SetError(val OptString) {
+	s.Error = val
+}
+
+// Ref: #/components/schemas/BatchResponse
+type BatchResponse struct {
+	// One result per event, in the order of the batch.
+	Results []BatchEventResult `json:"results"`
+}
+
+// GetResults returns the value of Results.
+func (s *BatchResponse) GetResults() []BatchEventResult {
+	return s.Results
+}
+
+// SetResults sets the value of Results.
+func (s *BatchResponse) SetResults(val []BatchEventResult) {
+	s.Results = val
+}
+
+func (*BatchResponse) userEventBatchRes() {}
+
+// Ref: #/components/schemas/EndpointVerificationData
+type EndpointVerificationData struct {
+	// A random value the endpoint must echo back.
+	Challenge string `json:"challenge"`
+}
+
+// GetChallenge returns the value of Challenge.
+func (s *EndpointVerificationData) GetChallenge() string {
+	return s.Challenge
+}
+
+// SetChallenge sets the value of Challenge.
+func (s *EndpointVerificationData) SetChallenge(val string) {
+	s.Challenge = val
+}
+
+// Sent when an endpoint is registered, to prove that its owner controls it.
+// The endpoint is only activated if it responds with the challenge in WebhookResponse.
+// Ref: #/components/schemas/EndpointVerificationEvent
+type EndpointVerificationEvent struct {
+	// The ID of the event, the same for every delivery of it.
+	ID string `json:"id"`
+	// When the event occurred, unlike webhook-timestamp which changes on every attempt.
+	Timestamp time.Time `json:"timestamp"`
+	// The service which produced the event.
+	Producer OptString `json:"producer"`
+	// The tenant the event belongs to.
+	Tenant OptString `json:"tenant"`
+	// The type of the webhook event.
+	Type string `json:"type"`
+	// The schema version of data. A missing version means 1.
+	Version OptInt                   `json:"version"`
+	Data    EndpointVerificationData `json:"data"`
+}
+
+// GetID returns the value of ID.
+func (s *EndpointVerificationEvent) GetID() string {
+	return s.ID
+}
+
+// GetTimestamp returns the value of Timestamp.
+func (s *EndpointVerificationEvent) GetTimestamp() time.Time {
+	return s.Timestamp
+}
+
+// GetProducer returns the value of Producer.
+func (s *EndpointVerificationEvent) GetProducer() OptString {
+	return s.Producer
+}
+
+// GetTenant returns the value of Tenant.
+func (s *EndpointVerificationEvent) GetTenant() OptString {
+	return s.Tenant
+}
+
+// GetType returns the value of Type.
+func (s *EndpointVerificationEvent) GetType() string {
+	return s.Type
+}
+
+// GetVersion returns the value of Version.
+func (s *EndpointVerificationEvent) GetVersion() OptInt {
+	return s.Version
+}
+
+// GetData returns the value of Data.
+func (s *EndpointVerificationEvent) GetData() EndpointVerificationData {
+	return s.Data
+}
+
+// SetID sets the value of ID.
+func (s *EndpointVerificationEvent) SetID(val string) {
+	s.ID = val
+}
+
+// SetTimestamp sets the value of Timestamp.
+func (s *EndpointVerificationEvent) SetTimestamp(val time.Time) {
+	s.Timestamp = val
+}
+
+// SetProducer sets the value of Producer.
+func (s *EndpointVerificationEvent) SetProducer(val OptString) {
+	s.Producer = val
+}
+
+// SetTenant sets the value of Tenant.
+func (s *EndpointVerificationEvent) SetTenant(val OptString) {
+	s.Tenant = val
+}
+
+// SetType sets the value of Type.
+func (s *EndpointVerificationEvent) SetType(val string) {
+	s.Type = val
+}
+
+// SetVersion sets the value of Version.
+func (s *EndpointVerificationEvent) SetVersion(val OptInt) {
+	s.Version = val
+}
+
+// SetData sets the value of Data.
+func (s *EndpointVerificationEvent) SetData(val EndpointVerificationData) {
+	s.Data = val
+}
+
 // Ref: #/components/schemas/ErrorResponse
 type ErrorResponse struct {
 	// Error message.
@@ -22,58 +185,599 @@ func (s *ErrorResponse) SetError(val string) {
 	s.Error = val
 }
 
+// NewOptInt returns new OptInt with value set to v.
+func NewOptInt(v int) OptInt {
+	return OptInt{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptInt is optional int.
+type OptInt struct {
+	Value int
+	Set   bool
+}
+
+// IsSet returns true if OptInt was set.
+func (o OptInt) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptInt) Reset() {
+	var v int
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptInt) SetTo(v int) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptInt) Get() (v int, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptInt) Or(d int) int {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// NewOptString returns new OptString with value set to v.
+func NewOptString(v string) OptString {
+	return OptString{
+		Value: v,
+		Set:   true,
+	}
+}
+
+// OptString is optional string.
+type OptString struct {
+	Value string
+	Set   bool
+}
+
+// IsSet returns true if OptString was set.
+func (o OptString) IsSet() bool { return o.Set }
+
+// Reset unsets value.
+func (o *OptString) Reset() {
+	var v string
+	o.Value = v
+	o.Set = false
+}
+
+// SetTo sets value to v.
+func (o *OptString) SetTo(v string) {
+	o.Set = true
+	o.Value = v
+}
+
+// Get returns value and boolean that denotes whether value was set.
+func (o OptString) Get() (v string, ok bool) {
+	if !o.Set {
+		return v, false
+	}
+	return o.Value, true
+}
+
+// Or returns value if set, or given parameter if does not.
+func (o OptString) Or(d string) string {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return d
+}
+
+// Ref: #/components/schemas/UserCreatedData
+type UserCreatedData struct {
+	// The ID of the created user.
+	ID string `json:"id"`
+	// The email address of the user.
+	Email string `json:"email"`
+	// The display name of the user.
+	Name string `json:"name"`
+}
+
+// GetID returns the value of ID.
+func (s *UserCreatedData) GetID() string {
+	return s.ID
+}
+
+// GetEmail returns the value of Email.
+func (s *UserCreatedData) GetEmail() string {
+	return s.Email
+}
+
+// GetName returns the value of Name.
+func (s *UserCreatedData) GetName() string {
+	return s.Name
+}
+
+// SetID sets the value of ID.
+func (s *UserCreatedData) SetID(val string) {
+	s.ID = val
+}
+
+// SetEmail sets the value of Email.
+func (s *UserCreatedData) SetEmail(val string) {
+	s.Email = val
+}
+
+// SetName sets the value of Name.
+func (s *UserCreatedData) SetName(val string) {
+	s.Name = val
+}
+
+// Ref: #/components/schemas/UserCreatedEvent
+type UserCreatedEvent struct {
+	// The ID of the event, the same for every delivery of it.
+	ID string `json:"id"`
+	// When the event occurred, unlike webhook-timestamp which changes on every attempt.
+	Timestamp time.Time `json:"timestamp"`
+	// The service which produced the event.
+	Producer OptString `json:"producer"`
+	// The tenant the event belongs to.
+	Tenant OptString `json:"tenant"`
+	// The type of the webhook event.
+	Type string `json:"type"`
+	// The schema version of data. A missing version means 1.
+	Version OptInt          `json:"version"`
+	Data    UserCreatedData `json:"data"`
+}
+
+// GetID returns the value of ID.
+func (s *UserCreatedEvent) GetID() string {
+	return s.ID
+}
+
+// GetTimestamp returns the value of Timestamp.
+func (s *UserCreatedEvent) GetTimestamp() time.Time {
+	return s.Timestamp
+}
+
+// GetProducer returns the value of Producer.
+func (s *UserCreatedEvent) GetProducer() OptString {
+	return s.Producer
+}
+
+// GetTenant returns the value of Tenant.
+func (s *UserCreatedEvent) GetTenant() OptString {
+	return s.Tenant
+}
+
+// GetType returns the value of Type.
+func (s *UserCreatedEvent) GetType() string {
+	return s.Type
+}
+
+// GetVersion returns the value of Version.
+func (s *UserCreatedEvent) GetVersion() OptInt {
+	return s.Version
+}
+
+// GetData returns the value of Data.
+func (s *UserCreatedEvent) GetData() UserCreatedData {
+	return s.Data
+}
+
+// SetID sets the value of ID.
+func (s *UserCreatedEvent) SetID(val string) {
+	s.ID = val
+}
+
+// SetTimestamp sets the value of Timestamp.
+func (s *UserCreatedEvent) SetTimestamp(val time.Time) {
+	s.Timestamp = val
+}
+
+// SetProducer sets the value of Producer.
+func (s *UserCreatedEvent) SetProducer(val OptString) {
+	s.Producer = val
+}
+
+// SetTenant sets the value of Tenant.
+func (s *UserCreatedEvent) SetTenant(val OptString) {
+	s.Tenant = val
+}
+
+// SetType sets the value of Type.
+func (s *UserCreatedEvent) SetType(val string) {
+	s.Type = val
+}
+
+// SetVersion sets the value of Version.
+func (s *UserCreatedEvent) SetVersion(val OptInt) {
+	s.Version = val
+}
+
+// SetData sets the value of Data.
+func (s *UserCreatedEvent) SetData(val UserCreatedData) {
+	s.Data = val
+}
+
+// Ref: #/components/schemas/UserDeletedData
+type UserDeletedData struct {
+	// The ID of the deleted user.
+	ID string `json:"id"`
+}
+
+// GetID returns the value of ID.
+func (s *UserDeletedData) GetID() string {
+	return s.ID
+}
+
+// SetID sets the value of ID.
+func (s *UserDeletedData) SetID(val string) {
+	s.ID = val
+}
+
+// Ref: #/components/schemas/UserDeletedEvent
+type UserDeletedEvent struct {
+	// The ID of the event, the same for every delivery of it.
+	ID string `json:"id"`
+	// When the event occurred, unlike webhook-timestamp which changes on every attempt.
+	Timestamp time.Time `json:"timestamp"`
+	// The service which produced the event.
+	Producer OptString `json:"producer"`
+	// The tenant the event belongs to.
+	Tenant OptString `json:"tenant"`
+	// The type of the webhook event.
+	Type string `json:"type"`
+	// The schema version of data. A missing version means 1.
+	Version OptInt          `json:"version"`
+	Data    UserDeletedData `json:"data"`
+}
+
+// GetID returns the value of ID.
+func (s *UserDeletedEvent) GetID() string {
+	return s.ID
+}
+
+// GetTimestamp returns the value of Timestamp.
+func (s *UserDeletedEvent) GetTimestamp() time.Time {
+	return s.Timestamp
+}
+
+// GetProducer returns the value of Producer.
+func (s *UserDeletedEvent) GetProducer() OptString {
+	return s.Producer
+}
+
+// GetTenant returns the value of Tenant.
+func (s *UserDeletedEvent) GetTenant() OptString {
+	return s.Tenant
+}
+
+// GetType returns the value of Type.
+func (s *UserDeletedEvent) GetType() string {
+	return s.Type
+}
+
+// GetVersion returns the value of Version.
+func (s *UserDeletedEvent) GetVersion() OptInt {
+	return s.Version
+}
+
+// GetData returns the value of Data.
+func (s *UserDeletedEvent) GetData() UserDeletedData {
+	return s.Data
+}
+
+// SetID sets the value of ID.
+func (s *UserDeletedEvent) SetID(val string) {
+	s.ID = val
+}
+
+// SetTimestamp sets the value of Timestamp.
+func (s *UserDeletedEvent) SetTimestamp(val time.Time) {
+	s.Timestamp = val
+}
+
+// SetProducer sets the value of Producer.
+func (s *UserDeletedEvent) SetProducer(val OptString) {
+	s.Producer = val
+}
+
+// SetTenant sets the value of Tenant.
+func (s *UserDeletedEvent) SetTenant(val OptString) {
+	s.Tenant = val
+}
+
+// SetType sets the value of Type.
+func (s *UserDeletedEvent) SetType(val string) {
+	s.Type = val
+}
+
+// SetVersion sets the value of Version.
+func (s *UserDeletedEvent) SetVersion(val OptInt) {
+	s.Version = val
+}
+
+// SetData sets the value of Data.
+func (s *UserDeletedEvent) SetData(val UserDeletedData) {
+	s.Data = val
+}
+
 type UserEventBadRequest ErrorResponse
 
 func (*UserEventBadRequest) userEventRes() {}
 
+type UserEventBatchBadRequest ErrorResponse
+
+func (*UserEventBatchBadRequest) userEventBatchRes() {}
+
+type UserEventBatchUnauthorized ErrorResponse
+
+func (*UserEventBatchUnauthorized) userEventBatchRes() {}
+
 type UserEventUnauthorized ErrorResponse
 
 func (*UserEventUnauthorized) userEventRes() {}
 
-// Ref: #/components/schemas/WebhookEvent
-type WebhookEvent struct {
+// Ref: #/components/schemas/UserUpdatedData
+type UserUpdatedData struct {
+	// The ID of the updated user.
+	ID string `json:"id"`
+	// The new email address, if it changed.
+	Email OptString `json:"email"`
+	// The new display name, if it changed.
+	Name OptString `json:"name"`
+}
+
+// GetID returns the value of ID.
+func (s *UserUpdatedData) GetID() string {
+	return s.ID
+}
+
+// GetEmail returns the value of Email.
+func (s *UserUpdatedData) GetEmail() OptString {
+	return s.Email
+}
+
+// GetName returns the value of Name.
+func (s *UserUpdatedData) GetName() OptString {
+	return s.Name
+}
+
+// SetID sets the value of ID.
+func (s *UserUpdatedData) SetID(val string) {
+	s.ID = val
+}
+
+// SetEmail sets the value of Email.
+func (s *UserUpdatedData) SetEmail(val OptString) {
+	s.Email = val
+}
+
+// SetName sets the value of Name.
+func (s *UserUpdatedData) SetName(val OptString) {
+	s.Name = val
+}
+
+// Ref: #/components/schemas/UserUpdatedEvent
+type UserUpdatedEvent struct {
+	// The ID of the event, the same for every delivery of it.
+	ID string `json:"id"`
+	// When the event occurred, unlike webhook-timestamp which changes on every attempt.
+	Timestamp time.Time `json:"timestamp"`
+	// The service which produced the event.
+	Producer OptString `json:"producer"`
+	// The tenant the event belongs to.
+	Tenant OptString `json:"tenant"`
 	// The type of the webhook event.
 	Type string `json:"type"`
-	// The event payload data.
-	Data WebhookEventData `json:"data"`
+	// The schema version of data. A missing version means 1.
+	Version OptInt          `json:"version"`
+	Data    UserUpdatedData `json:"data"`
+}
+
+// GetID returns the value of ID.
+func (s *UserUpdatedEvent) GetID() string {
+	return s.ID
+}
+
+// GetTimestamp returns the value of Timestamp.
+func (s *UserUpdatedEvent) GetTimestamp() time.Time {
+	return s.Timestamp
+}
+
+// GetProducer returns the value of Producer.
+func (s *UserUpdatedEvent) GetProducer() OptString {
+	return s.Producer
+}
+
+// GetTenant returns the value of Tenant.
+func (s *UserUpdatedEvent) GetTenant() OptString {
+	return s.Tenant
 }
 
 // GetType returns the value of Type.
-func (s *WebhookEvent) GetType() string {
+func (s *UserUpdatedEvent) GetType() string {
 	return s.Type
 }
 
+// GetVersion returns the value of Version.
+func (s *UserUpdatedEvent) GetVersion() OptInt {
+	return s.Version
+}
+
 // GetData returns the value of Data.
-func (s *WebhookEvent) GetData() WebhookEventData {
+func (s *UserUpdatedEvent) GetData() UserUpdatedData {
 	return s.Data
 }
 
+// SetID sets the value of ID.
+func (s *UserUpdatedEvent) SetID(val string) {
+	s.ID = val
+}
+
+// SetTimestamp sets the value of Timestamp.
+func (s *UserUpdatedEvent) SetTimestamp(val time.Time) {
+	s.Timestamp = val
+}
+
+// SetProducer sets the value of Producer.
+func (s *UserUpdatedEvent) SetProducer(val OptString) {
+	s.Producer = val
+}
+
+// SetTenant sets the value of Tenant.
+func (s *UserUpdatedEvent) SetTenant(val OptString) {
+	s.Tenant = val
+}
+
 // SetType sets the value of Type.
-func (s *WebhookEvent) SetType(val string) {
+func (s *UserUpdatedEvent) SetType(val string) {
 	s.Type = val
 }
 
+// SetVersion sets the value of Version.
+func (s *UserUpdatedEvent) SetVersion(val OptInt) {
+	s.Version = val
+}
+
 // SetData sets the value of Data.
-func (s *WebhookEvent) SetData(val WebhookEventData) {
+func (s *UserUpdatedEvent) SetData(val UserUpdatedData) {
 	s.Data = val
 }
 
-// The event payload data.
-type WebhookEventData map[string]jx.Raw
+// A webhook event, discriminated by its type.
+// Ref: #/components/schemas/WebhookEvent
+// WebhookEvent represents sum type.
+type WebhookEvent struct {
+	Type                      WebhookEventType // switch on this field
+	UserCreatedEvent          UserCreatedEvent
+	UserUpdatedEvent          UserUpdatedEvent
+	UserDeletedEvent          UserDeletedEvent
+	EndpointVerificationEvent EndpointVerificationEvent
+}
+
+// WebhookEventType is oneOf type of WebhookEvent.
+type WebhookEventType string
+
+// Possible values for WebhookEventType.
+const (
+	UserCreatedEventWebhookEvent          WebhookEventType = "user.created"
+	UserUpdatedEventWebhookEvent          WebhookEventType = "user.updated"
+	UserDeletedEventWebhookEvent          WebhookEventType = "user.deleted"
+	EndpointVerificationEventWebhookEvent WebhookEventType = "endpoint.verification"
+)
+
+// IsUserCreatedEvent reports whether WebhookEvent is UserCreatedEvent.
+func (s WebhookEvent) IsUserCreatedEvent() bool { return s.Type == UserCreatedEventWebhookEvent }
+
+// IsUserUpdatedEvent reports whether WebhookEvent is UserUpdatedEvent.
+func (s WebhookEvent) IsUserUpdatedEvent() bool { return s.Type == UserUpdatedEventWebhookEvent }
+
+// IsUserDeletedEvent reports whether WebhookEvent is UserDeletedEvent.
+func (s WebhookEvent) IsUserDeletedEvent() bool { return s.Type == UserDeletedEventWebhookEvent }
+
+// IsEndpointVerificationEvent reports whether WebhookEvent is EndpointVerificationEvent.
+func (s WebhookEvent) IsEndpointVerificationEvent() bool {
+	return s.Type == EndpointVerificationEventWebhookEvent
+}
+
+// SetUserCreatedEvent sets WebhookEvent to UserCreatedEvent.
+func (s *WebhookEvent) SetUserCreatedEvent(v UserCreatedEvent) {
+	s.Type = UserCreatedEventWebhookEvent
+	s.UserCreatedEvent = v
+}
+
+// GetUserCreatedEvent returns UserCreatedEvent and true boolean if WebhookEvent is UserCreatedEvent.
+func (s WebhookEvent) GetUserCreatedEvent() (v UserCreatedEvent, ok bool) {
+	if !s.IsUserCreatedEvent() {
+		return v, false
+	}
+	return s.UserCreatedEvent, true
+}
+
+// NewUserCreatedEventWebhookEvent returns new WebhookEvent from UserCreatedEvent.
+func NewUserCreatedEventWebhookEvent(v UserCreatedEvent) WebhookEvent {
+	var s WebhookEvent
+	s.SetUserCreatedEvent(v)
+	return s
+}
+
+// SetUserUpdatedEvent sets WebhookEvent to UserUpdatedEvent.
+func (s *WebhookEvent) SetUserUpdatedEvent(v UserUpdatedEvent) {
+	s.Type = UserUpdatedEventWebhookEvent
+	s.UserUpdatedEvent = v
+}
+
+// GetUserUpdatedEvent returns UserUpdatedEvent and true boolean if WebhookEvent is UserUpdatedEvent.
+func (s WebhookEvent) GetUserUpdatedEvent() (v UserUpdatedEvent, ok bool) {
+	if !s.IsUserUpdatedEvent() {
+		return v, false
+	}
+	return s.UserUpdatedEvent, true
+}
+
+// NewUserUpdatedEventWebhookEvent returns new WebhookEvent from UserUpdatedEvent.
+func NewUserUpdatedEventWebhookEvent(v UserUpdatedEvent) WebhookEvent {
+	var s WebhookEvent
+	s.SetUserUpdatedEvent(v)
+	return s
+}
+
+// SetUserDeletedEvent sets WebhookEvent to UserDeletedEvent.
+func (s *WebhookEvent) SetUserDeletedEvent(v UserDeletedEvent) {
+	s.Type = UserDeletedEventWebhookEvent
+	s.UserDeletedEvent = v
+}
+
+// GetUserDeletedEvent returns UserDeletedEvent and true boolean if WebhookEvent is UserDeletedEvent.
+func (s WebhookEvent) GetUserDeletedEvent() (v UserDeletedEvent, ok bool) {
+	if !s.IsUserDeletedEvent() {
+		return v, false
+	}
+	return s.UserDeletedEvent, true
+}
+
+// NewUserDeletedEventWebhookEvent returns new WebhookEvent from UserDeletedEvent.
+func NewUserDeletedEventWebhookEvent(v UserDeletedEvent) WebhookEvent {
+	var s WebhookEvent
+	s.SetUserDeletedEvent(v)
+	return s
+}
+
+// SetEndpointVerificationEvent sets WebhookEvent to EndpointVerificationEvent.
+func (s *WebhookEvent) SetEndpointVerificationEvent(v EndpointVerificationEvent) {
+	s.Type = EndpointVerificationEventWebhookEvent
+	s.EndpointVerificationEvent = v
+}
 
-func (s *WebhookEventData) init() WebhookEventData {
-	m := *s
-	if m == nil {
-		m = map[string]jx.Raw{}
-		*s = m
+// GetEndpointVerificationEvent returns EndpointVerificationEvent and true boolean if WebhookEvent is EndpointVerificationEvent.
+func (s WebhookEvent) GetEndpointVerificationEvent() (v EndpointVerificationEvent, ok bool) {
+	if !s.IsEndpointVerificationEvent() {
+		return v, false
 	}
-	return m
+	return s.EndpointVerificationEvent, true
 }
 
+// NewEndpointVerificationEventWebhookEvent returns new WebhookEvent from EndpointVerificationEvent.
+func NewEndpointVerificationEventWebhookEvent(v EndpointVerificationEvent) WebhookEvent {
+	var s WebhookEvent
+	s.SetEndpointVerificationEvent(v)
+	return s
+}
+
+type WebhookEventBatch []WebhookEvent
+
 // Ref: #/components/schemas/WebhookResponse
 type WebhookResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+	// The challenge of an endpoint.verification event, echoed back.
+	Challenge OptString `json:"challenge"`
 }
 
 // GetSuccess returns the value of Success.
@@ -86,6 +790,11 @@ func (s *WebhookResponse) GetMessage() string {
 	return s.Message
 }
 
+// GetChallenge returns the value of Challenge.
+func (s *WebhookResponse) GetChallenge() OptString {
+	return s.Challenge
+}
+
 // SetSuccess sets the value of Success.
 func (s *WebhookResponse) SetSuccess(val bool) {
 	s.Success = val
@@ -96,4 +805,9 @@ func (s *WebhookResponse) SetMessage(val string) {
 	s.Message = val
 }
 
+// SetChallenge sets the value of Challenge.
+func (s *WebhookResponse) SetChallenge(val OptString) {
+	s.Challenge = val
+}
+
 func (*WebhookResponse) userEventRes() {}
@@ -35,7 +35,12 @@ type WebhookHandler interface {
 	//
 	// Webhook sent when a user event occurs (created, updated, deleted).
 	//
-	UserEvent(ctx context.Context, req *WebhookEvent) (UserEventRes, error)
+	UserEvent(ctx context.Context, req WebhookEvent) (UserEventRes, error)
+	// UserEventBatch implements userEventBatch operation.
+	//
+	// Several user events sent in one request. Each event is handled on its own and gets its own result.
+	//
+	UserEventBatch(ctx context.Context, req WebhookEventBatch) (UserEventBatchRes, error)
 }
 
 // WebhookServer implements http server based on OpenAPI v3 specification and
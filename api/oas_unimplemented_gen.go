@@ -16,6 +16,13 @@ var _ WebhookHandler = UnimplementedHandler{}
 // UserEvent implements userEvent operation.
 //
 // Webhook sent when a user event occurs (created, updated, deleted).
-func (UnimplementedHandler) UserEvent(ctx context.Context, req *WebhookEvent) (r UserEventRes, _ error) {
+func (UnimplementedHandler) UserEvent(ctx context.Context, req WebhookEvent) (r UserEventRes, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
+// UserEventBatch implements userEventBatch operation.
+//
+// Several user events sent in one request. Each event is handled on its own and gets its own result.
+func (UnimplementedHandler) UserEventBatch(ctx context.Context, req WebhookEventBatch) (r UserEventBatchRes, _ error) {
 	return r, ht.ErrNotImplemented
 }
@@ -0,0 +1,9 @@
+package api
+
+import _ "embed"
+
+// OpenAPISpec is the OpenAPI document this package is generated from.
+// It is embedded so that event payloads can be validated against it at runtime.
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
@@ -0,0 +1,308 @@
+// Package attemptlog records every webhook delivery attempt, so that a claim like
+// "we never got the webhook" can be answered with what was sent and what came back.
+//
+// A WebhookClient created with client.WithAttemptLog adds an Attempt to a Store
+// for each request it makes, and Query finds them again by endpoint, event type,
+// message ID and time range.
+package attemptlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/internal/jsonl"
+)
+
+// MaxResponseBody is the number of response body bytes kept in an Attempt.
+const MaxResponseBody = 4 << 10 // 4 KiB
+
+// redactedHeaders are request and response headers which are never recorded.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Set-Cookie2"}
+
+// Attempt is the record of one delivery attempt.
+type Attempt struct {
+	MsgID string `json:"msg_id"`
+	// Endpoint identifies the endpoint, such as its dispatch.Endpoint ID; it is the URL if there is none.
+	Endpoint  string    `json:"endpoint"`
+	URL       string    `json:"url"`
+	EventType string    `json:"event_type,omitempty"`
+	At        time.Time `json:"at"`
+	// RequestHeaders are the headers sent, without credentials.
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+	// RequestBodySHA256 is the hex-encoded SHA-256 hash of the body sent.
+	RequestBodySHA256 string `json:"request_body_sha256"`
+	// RequestBody is the body sent, if the client records bodies.
+	RequestBody string `json:"request_body,omitempty"`
+	// StatusCode is 0 if there was no response.
+	StatusCode int `json:"status_code,omitempty"`
+	// ResponseBody is the response body, truncated to MaxResponseBody bytes.
+	ResponseBody string        `json:"response_body,omitempty"`
+	Latency      time.Duration `json:"latency"`
+	// ErrorClass classifies a failed attempt: "status", "transport", "canceled",
+	// "circuit_open", "blocked" or "other".
+	ErrorClass string `json:"error_class,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK reports whether the endpoint accepted the request.
+func (a *Attempt) OK() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// RedactHeaders returns a copy of header without credentials, such as
+// Authorization in a request or Set-Cookie in a response.
+func RedactHeaders(header http.Header) http.Header {
+	h := header.Clone()
+	for _, k := range redactedHeaders {
+		h.Del(k)
+	}
+	return h
+}
+
+// TruncateBody limits a response body to MaxResponseBody bytes.
+func TruncateBody(body []byte) string {
+	if len(body) > MaxResponseBody {
+		body = body[:MaxResponseBody]
+	}
+	return string(body)
+}
+
+// Filter selects attempts. Zero fields match every attempt.
+type Filter struct {
+	// Endpoint matches the endpoint ID or its URL.
+	Endpoint  string
+	EventType string
+	MsgID     string
+	// Since and Until bound the time of the attempt, inclusive and exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit keeps the newest Limit matching attempts.
+	Limit int
+}
+
+// Match reports whether the attempt is selected by the filter, ignoring Limit.
+func (f Filter) Match(a *Attempt) bool {
+	switch {
+	case f.Endpoint != "" && f.Endpoint != a.Endpoint && f.Endpoint != a.URL:
+		return false
+	case f.EventType != "" && f.EventType != a.EventType:
+		return false
+	case f.MsgID != "" && f.MsgID != a.MsgID:
+		return false
+	case !f.Since.IsZero() && a.At.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !a.At.Before(f.Until):
+		return false
+	default:
+		return true
+	}
+}
+
+// apply returns the attempts selected by the filter, oldest first.
+func (f Filter) apply(attempts []*Attempt) []*Attempt {
+	m := matcher{f: f}
+	for _, a := range attempts {
+		m.add(a)
+	}
+	return m.result()
+}
+
+// matcher collects the attempts selected by a filter, keeping at most about
+// twice Limit of them while attempts are added.
+type matcher struct {
+	f   Filter
+	out []*Attempt
+}
+
+func (m *matcher) add(a *Attempt) {
+	if !m.f.Match(a) {
+		return
+	}
+	m.out = append(m.out, a)
+	if m.f.Limit > 0 && len(m.out) >= 2*m.f.Limit {
+		m.trim()
+	}
+}
+
+// trim sorts the attempts and keeps the newest Limit of them.
+func (m *matcher) trim() {
+	slices.SortStableFunc(m.out, func(a, b *Attempt) int {
+		return a.At.Compare(b.At)
+	})
+	if m.f.Limit > 0 && len(m.out) > m.f.Limit {
+		m.out = slices.Clone(m.out[len(m.out)-m.f.Limit:])
+	}
+}
+
+// result returns the attempts selected, oldest first.
+func (m *matcher) result() []*Attempt {
+	m.trim()
+	return m.out
+}
+
+// Store keeps delivery attempts.
+type Store interface {
+	// Add records an attempt.
+	Add(ctx context.Context, a *Attempt) error
+	// Query returns the attempts selected by f, oldest first.
+	Query(ctx context.Context, f Filter) ([]*Attempt, error)
+}
+
+// MemoryStore is a Store holding attempts in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts []*Attempt
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Add records an attempt.
+func (s *MemoryStore) Add(ctx context.Context, a *Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, a)
+	return nil
+}
+
+// Query returns the attempts selected by f, oldest first.
+func (s *MemoryStore) Query(ctx context.Context, f Filter) ([]*Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return f.apply(s.attempts), nil
+}
+
+// DefaultMaxSize is the size at which a FileStore starts a new log.
+const DefaultMaxSize = 64 << 20 // 64 MiB
+
+// FileStore is a Store backed by a JSON Lines log with one attempt per line.
+//
+// The log is only appended to, so several processes may share it. Once it
+// reaches its maximum size it is renamed to path+".1", replacing the previous
+// one, and a new log is started; attempts older than that are dropped.
+// Queries read both files without holding attempts in memory.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	w       *jsonl.Writer
+}
+
+var _ Store = (*FileStore)(nil)
+
+// FileStoreOption configures a FileStore.
+type FileStoreOption func(*FileStore)
+
+// WithMaxSize sets the size in bytes at which the log is rotated.
+// The default is DefaultMaxSize; n <= 0 lets the log grow without bound.
+func WithMaxSize(n int64) FileStoreOption {
+	return func(s *FileStore) {
+		s.maxSize = n
+	}
+}
+
+// OpenFileStore opens or creates a FileStore at path.
+func OpenFileStore(path string, opts ...FileStoreOption) (*FileStore, error) {
+	s := &FileStore{path: path, maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	w, err := jsonl.OpenWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	s.w = w
+	return s, nil
+}
+
+// Add appends an attempt to the log.
+func (s *FileStore) Add(ctx context.Context, a *Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	return s.w.Append(a)
+}
+
+// rotate starts a new log if the current one has reached the maximum size.
+func (s *FileStore) rotate() error {
+	if s.maxSize <= 0 {
+		return nil
+	}
+	fi, err := s.w.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < s.maxSize {
+		return nil
+	}
+	// Another process may have rotated the log already
+	cur, err := os.Stat(s.path)
+	if err == nil && os.SameFile(fi, cur) {
+		err = os.Rename(s.path, s.path+".1")
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	w, err := jsonl.OpenWriter(s.path)
+	if err != nil {
+		return err
+	}
+	s.w.Close()
+	s.w = w
+	return nil
+}
+
+// Query returns the attempts selected by f, oldest first.
+func (s *FileStore) Query(ctx context.Context, f Filter) ([]*Attempt, error) {
+	return QueryFile(s.path, f)
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+// QueryFile returns the attempts selected by f from the log at path, oldest first.
+// It only reads the log and the previous one, so it is safe while another process appends to it.
+func QueryFile(path string, f Filter) ([]*Attempt, error) {
+	m := matcher{f: f}
+	for _, p := range []string{path + ".1", path} {
+		err := jsonl.Read(p, func(line []byte) error {
+			var a Attempt
+			// A line torn by a crash is skipped
+			if json.Unmarshal(line, &a) == nil {
+				m.add(&a)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m.result(), nil
+}
+
+// ParseTime parses a time bound for a Filter: an RFC 3339 time, or a duration
+// such as "24h" meaning that long before now.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
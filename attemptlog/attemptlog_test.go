@@ -0,0 +1,247 @@
+package attemptlog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// attempt returns an attempt to endpoint made i minutes after t0.
+func attempt(i int, endpoint string) *Attempt {
+	return &Attempt{
+		MsgID:      fmt.Sprintf("msg_%d", i),
+		Endpoint:   endpoint,
+		URL:        "https://" + endpoint + ".example.com/webhook",
+		EventType:  "user.created",
+		At:         t0.Add(time.Duration(i) * time.Minute),
+		StatusCode: http.StatusOK,
+	}
+}
+
+// msgIDs returns the message IDs of attempts.
+func msgIDs(attempts []*Attempt) []string {
+	ids := make([]string, len(attempts))
+	for i, a := range attempts {
+		ids[i] = a.MsgID
+	}
+	return ids
+}
+
+func checkMsgIDs(t *testing.T, attempts []*Attempt, want ...string) {
+	t.Helper()
+	got := msgIDs(attempts)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("attempts = %v, want %v", got, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	// Added out of order, as by concurrent deliveries
+	for _, i := range []int{2, 0, 4, 1, 3} {
+		endpoint := "ep_1"
+		if i%2 == 1 {
+			endpoint = "ep_2"
+		}
+		if err := s.Add(ctx, attempt(i, endpoint)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		f    Filter
+		want []string
+	}{
+		{name: "all", want: []string{"msg_0", "msg_1", "msg_2", "msg_3", "msg_4"}},
+		{name: "endpoint", f: Filter{Endpoint: "ep_2"}, want: []string{"msg_1", "msg_3"}},
+		{name: "endpoint URL", f: Filter{Endpoint: "https://ep_2.example.com/webhook"}, want: []string{"msg_1", "msg_3"}},
+		{name: "message", f: Filter{MsgID: "msg_2"}, want: []string{"msg_2"}},
+		{name: "event type", f: Filter{EventType: "user.deleted"}},
+		{name: "time range", f: Filter{Since: t0.Add(time.Minute), Until: t0.Add(3 * time.Minute)}, want: []string{"msg_1", "msg_2"}},
+		{name: "limit keeps the newest", f: Filter{Limit: 2}, want: []string{"msg_3", "msg_4"}},
+		{name: "limit after filtering", f: Filter{Endpoint: "ep_1", Limit: 2}, want: []string{"msg_2", "msg_4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Query(ctx, tt.f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMsgIDs(t, got, tt.want...)
+		})
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attempts.jsonl")
+	ctx := context.Background()
+	for i := range 2 {
+		s, err := OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(ctx, attempt(i, "ep_1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := QueryFile(path, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMsgIDs(t, got, "msg_0", "msg_1")
+}
+
+func TestFileStoreTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attempts.jsonl")
+	ctx := context.Background()
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(ctx, attempt(0, "ep_1")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A crash in the middle of a write leaves an incomplete line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"msg_id":"msg_torn","endp`)
+	f.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Add(ctx, attempt(1, "ep_1")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Query(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMsgIDs(t, got, "msg_0", "msg_1")
+}
+
+func TestFileStoreMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "attempts.jsonl")
+	const maxSize = 1 << 10
+	s, err := OpenFileStore(path, WithMaxSize(maxSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	const n = 100
+	for i := range n {
+		if err := s.Add(ctx, attempt(i, "ep_1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var size int64
+	for _, p := range []string{path, path + ".1"} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += fi.Size()
+	}
+	// Each file ends with at most one line past the maximum size
+	if size > 3*maxSize {
+		t.Errorf("log takes %d bytes, want at most about %d", size, 2*maxSize)
+	}
+
+	got, err := s.Query(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || len(got) == n {
+		t.Fatalf("%d attempts kept, want the newest of %d", len(got), n)
+	}
+	for i, a := range got {
+		if want := fmt.Sprintf("msg_%d", n-len(got)+i); a.MsgID != want {
+			t.Fatalf("attempt %d = %s, want %s", i, a.MsgID, want)
+		}
+	}
+
+	got, err = s.Query(ctx, Filter{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMsgIDs(t, got, "msg_97", "msg_98", "msg_99")
+}
+
+func TestFileStoreSharedFile(t *testing.T) {
+	// Two processes appending to the same log lose no attempts
+	path := filepath.Join(t.TempDir(), "attempts.jsonl")
+	ctx := context.Background()
+	const n = 50
+	var wg sync.WaitGroup
+	for _, endpoint := range []string{"ep_1", "ep_2"} {
+		s, err := OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range n {
+				if err := s.Add(ctx, attempt(i, endpoint)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, endpoint := range []string{"ep_1", "ep_2"} {
+		got, err := QueryFile(path, Filter{Endpoint: endpoint})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != n {
+			t.Errorf("%d attempts to %s, want %d", len(got), endpoint, n)
+		}
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Cookie", "session=secret")
+	h.Set("Set-Cookie", "session=secret; HttpOnly")
+	h.Set("Webhook-Id", "msg_1")
+
+	got := RedactHeaders(h)
+	for _, k := range []string{"Authorization", "Cookie", "Set-Cookie"} {
+		if v := got.Get(k); v != "" {
+			t.Errorf("%s = %q, want it redacted", k, v)
+		}
+	}
+	if v := got.Get("Webhook-Id"); v != "msg_1" {
+		t.Errorf("Webhook-Id = %q, want msg_1", v)
+	}
+	if h.Get("Authorization") == "" {
+		t.Error("RedactHeaders modified its argument")
+	}
+}
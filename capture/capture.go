@@ -0,0 +1,154 @@
+// Package capture defines the JSON Lines format of captured webhook deliveries.
+// A WebhookClient created with client.WithRecorder writes it, and cmd/replay sends
+// the deliveries again.
+//
+// Every line is one Record, a delivery as it went over the wire:
+//
+//	{
+//	  "time": "2025-01-01T12:00:00Z",          // when the request was sent
+//	  "url": "https://example.com/webhook",    // where it was sent
+//	  "headers": {                             // request headers, canonicalized
+//	    "Content-Type": ["application/json"],
+//	    "Webhook-Id": ["msg_..."],
+//	    "Webhook-Timestamp": ["1735732800"],
+//	    "Webhook-Signature": ["v1,..."]
+//	  },
+//	  "body": "{\"id\":\"evt_...\",...}",      // raw request body
+//	  "response": {                            // absent if there was no response
+//	    "status": 200,
+//	    "headers": {"Content-Type": ["application/json"]},
+//	    "body": "{\"success\":true,...}",
+//	    "latency_ms": 12.5
+//	  },
+//	  "error": "..."                           // why the delivery failed, if it did
+//	}
+//
+// Bodies are strings holding the exact bytes, so signatures can be verified against them.
+// Unknown fields are ignored, so the format can be extended compatibly.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Record is a captured webhook delivery.
+type Record struct {
+	Time     time.Time   `json:"time"`
+	URL      string      `json:"url"`
+	Headers  http.Header `json:"headers"`
+	Body     string      `json:"body"`
+	Response *Response   `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Response is the response to a captured delivery.
+type Response struct {
+	Status    int         `json:"status"`
+	Headers   http.Header `json:"headers,omitempty"`
+	Body      string      `json:"body,omitempty"`
+	LatencyMS float64     `json:"latency_ms"`
+}
+
+// MsgID returns the webhook-id of the delivery.
+func (r *Record) MsgID() string {
+	return r.Headers.Get("webhook-id")
+}
+
+// Read calls fn with every record read from r, with its 1-based line number.
+// Blank lines are skipped.
+func Read(r io.Reader, fn func(line int, rec *Record) error) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && len(bytes.TrimSpace(line)) > 0 {
+			var rec Record
+			if err := json.Unmarshal(line, &rec); err != nil {
+				return fmt.Errorf("capture: line %d: %w", n, err)
+			}
+			if err := fn(n, &rec); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFile returns the records of the file at path, or of standard input if path is "-".
+func ReadFile(path string) ([]*Record, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var records []*Record
+	err := Read(r, func(_ int, rec *Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// Recorder appends records to a capture, one line each.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+// NewRecorder creates a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// OpenRecorder opens the capture file at path for appending, creating it if needed.
+func OpenRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{w: f, c: f}, nil
+}
+
+// Record appends rec as a single line.
+func (r *Recorder) Record(rec *Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(b)
+	return err
+}
+
+// Close closes the file opened by OpenRecorder. It does nothing for a Recorder from NewRecorder.
+func (r *Recorder) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.c.Close()
+}
@@ -0,0 +1,296 @@
+// Package circuit implements a circuit breaker which stops deliveries to a failing endpoint.
+//
+// The breaker is closed while the endpoint works. Too many consecutive failures,
+// or a high failure rate, open it: requests are rejected without being sent.
+// After the probe interval the breaker is half-open and lets one request through
+// as a probe, which closes it again on success or reopens it on failure.
+// An endpoint still failing after DisableAfter is disabled until it is reset.
+package circuit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every request through.
+	Closed State = iota
+	// Open rejects requests until the next probe.
+	Open
+	// HalfOpen lets a single probe request through.
+	HalfOpen
+	// Disabled rejects every request until the breaker is reset.
+	Disabled
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	case Disabled:
+		return "disabled"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+var (
+	// ErrOpen is returned for requests rejected because the circuit is open.
+	ErrOpen = errors.New("circuit: open")
+	// ErrDisabled is returned for requests rejected because the endpoint is disabled.
+	ErrDisabled = errors.New("circuit: endpoint disabled")
+)
+
+// OpenError is returned by Allow while the circuit is open.
+type OpenError struct {
+	// RetryAt is when the next probe request will be let through.
+	RetryAt time.Time
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit: open until %s", e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// DisabledError is returned by Allow while the endpoint is disabled.
+type DisabledError struct {
+	Reason     string
+	DisabledAt time.Time
+}
+
+func (e *DisabledError) Error() string {
+	return fmt.Sprintf("circuit: endpoint disabled at %s: %s", e.DisabledAt.Format(time.RFC3339), e.Reason)
+}
+
+func (e *DisabledError) Is(target error) bool {
+	return target == ErrDisabled
+}
+
+// Config configures a Breaker. Zero fields take the defaults of DefaultConfig.
+type Config struct {
+	// ConsecutiveFailures opens the circuit after this many failures in a row.
+	ConsecutiveFailures int
+	// FailureRate opens the circuit when at least this share of the last Window requests failed.
+	FailureRate float64
+	// Window is the number of recent requests FailureRate is computed over.
+	// The rate is only checked once the window is full.
+	Window int
+	// ProbeInterval is how long the circuit stays open before a probe request.
+	ProbeInterval time.Duration
+	// DisableAfter disables the endpoint when it has kept failing this long
+	// since the circuit opened. A negative value never disables it.
+	DisableAfter time.Duration
+}
+
+// DefaultConfig returns the default configuration: open after 5 failures in a row
+// or half of the last 20 requests failing, probe every 30 seconds, and disable
+// the endpoint after 3 days of failures.
+func DefaultConfig() Config {
+	return Config{
+		ConsecutiveFailures: 5,
+		FailureRate:         0.5,
+		Window:              20,
+		ProbeInterval:       30 * time.Second,
+		DisableAfter:        72 * time.Hour,
+	}
+}
+
+// Status is a snapshot of a Breaker.
+type Status struct {
+	State               State
+	ConsecutiveFailures int
+	// LastError describes the last failure.
+	LastError string
+	// OpenedAt is when the endpoint started failing, while the circuit is not closed.
+	OpenedAt time.Time
+	// NextProbeAt is when the next probe is let through, while the circuit is open.
+	NextProbeAt time.Time
+	// DisabledAt and Reason are set while the endpoint is disabled.
+	DisabledAt time.Time
+	Reason     string
+}
+
+// Breaker is a circuit breaker for one endpoint. It is safe for concurrent use.
+type Breaker struct {
+	cfg Config
+
+	mu           sync.Mutex
+	status       Status
+	window       []bool // recent outcomes, true for failure
+	next         int    // next index of window to write
+	probeStarted time.Time
+}
+
+// New creates a closed Breaker.
+func New(cfg Config) *Breaker {
+	def := DefaultConfig()
+	if cfg.ConsecutiveFailures <= 0 {
+		cfg.ConsecutiveFailures = def.ConsecutiveFailures
+	}
+	if cfg.FailureRate <= 0 {
+		cfg.FailureRate = def.FailureRate
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = def.Window
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = def.ProbeInterval
+	}
+	if cfg.DisableAfter == 0 {
+		cfg.DisableAfter = def.DisableAfter
+	}
+	return &Breaker{cfg: cfg}
+}
+
+// Allow reports whether a request may be sent now, returning an *OpenError
+// or a *DisabledError if not. Every allowed request should be reported
+// with Success or Failure once it is done.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.status.State {
+	case Open:
+		if now.Before(b.status.NextProbeAt) {
+			return &OpenError{RetryAt: b.status.NextProbeAt}
+		}
+		b.status.State = HalfOpen
+		b.probeStarted = now
+		return nil
+	case HalfOpen:
+		// Allow another probe if the last one never reported back.
+		if now.Before(b.probeStarted.Add(b.cfg.ProbeInterval)) {
+			return &OpenError{RetryAt: b.probeStarted.Add(b.cfg.ProbeInterval)}
+		}
+		b.probeStarted = now
+		return nil
+	case Disabled:
+		return &DisabledError{Reason: b.status.Reason, DisabledAt: b.status.DisabledAt}
+	default:
+		return nil
+	}
+}
+
+// Success records a request the endpoint handled, closing a half-open circuit.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status.State == Disabled {
+		return
+	}
+	b.record(false)
+	if b.status.State == HalfOpen {
+		b.closeLocked()
+	}
+	b.status.ConsecutiveFailures = 0
+}
+
+// Failure records a request the endpoint failed, with a description of the failure.
+// It may open the circuit, reopen a half-open one, or disable the endpoint.
+// Failures of requests sent before the circuit opened don't delay the next probe.
+func (b *Breaker) Failure(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status.State == Disabled {
+		return
+	}
+	now := time.Now()
+	b.record(true)
+	b.status.ConsecutiveFailures++
+	b.status.LastError = reason
+
+	switch b.status.State {
+	case Closed:
+		if b.status.ConsecutiveFailures >= b.cfg.ConsecutiveFailures || b.failureRate() >= b.cfg.FailureRate {
+			b.status.State = Open
+			b.status.OpenedAt = now
+			b.status.NextProbeAt = now.Add(b.cfg.ProbeInterval)
+		}
+	case HalfOpen:
+		if b.cfg.DisableAfter > 0 && now.Sub(b.status.OpenedAt) >= b.cfg.DisableAfter {
+			b.disableLocked(now, fmt.Sprintf("failing since %s: %s", b.status.OpenedAt.Format(time.RFC3339), reason))
+			return
+		}
+		b.status.State = Open
+		b.status.NextProbeAt = now.Add(b.cfg.ProbeInterval)
+	}
+}
+
+// Disable disables the endpoint with the given reason until Reset is called.
+func (b *Breaker) Disable(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.disableLocked(time.Now(), reason)
+}
+
+func (b *Breaker) disableLocked(now time.Time, reason string) {
+	b.status.State = Disabled
+	b.status.DisabledAt = now
+	b.status.Reason = reason
+	b.status.NextProbeAt = time.Time{}
+}
+
+// Reset closes the circuit and re-enables a disabled endpoint.
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeLocked()
+	b.status.ConsecutiveFailures = 0
+	b.status.LastError = ""
+}
+
+func (b *Breaker) closeLocked() {
+	b.status = Status{
+		State:               Closed,
+		ConsecutiveFailures: b.status.ConsecutiveFailures,
+		LastError:           b.status.LastError,
+	}
+	b.window = b.window[:0]
+	b.next = 0
+}
+
+// Status returns a snapshot of the breaker.
+func (b *Breaker) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// record adds an outcome to the failure rate window. b.mu must be held.
+func (b *Breaker) record(failed bool) {
+	if len(b.window) < b.cfg.Window {
+		b.window = append(b.window, failed)
+		return
+	}
+	b.window[b.next] = failed
+	b.next = (b.next + 1) % b.cfg.Window
+}
+
+// failureRate returns the share of failures in a full window, or 0 if it is not full yet.
+func (b *Breaker) failureRate() float64 {
+	if len(b.window) < b.cfg.Window {
+		return 0
+	}
+	failures := 0
+	for _, failed := range b.window {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(b.window))
+}
@@ -0,0 +1,146 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const probeInterval = 20 * time.Millisecond
+
+func TestBreakerTransitions(t *testing.T) {
+	type step struct {
+		op string // allow, success, failure, wait or reset
+		// err is the error allow must return
+		err error
+		// want is the state after the step
+		want State
+	}
+	tests := []struct {
+		name  string
+		cfg   Config
+		steps []step
+	}{
+		{
+			name: "consecutive failures open",
+			cfg:  Config{ConsecutiveFailures: 3, ProbeInterval: time.Hour},
+			steps: []step{
+				{op: "failure", want: Closed},
+				{op: "failure", want: Closed},
+				{op: "success", want: Closed},
+				{op: "failure", want: Closed},
+				{op: "failure", want: Closed},
+				{op: "failure", want: Open},
+				{op: "allow", err: ErrOpen, want: Open},
+			},
+		},
+		{
+			name: "failure rate opens once the window is full",
+			cfg:  Config{ConsecutiveFailures: 100, FailureRate: 0.5, Window: 4, ProbeInterval: time.Hour},
+			steps: []step{
+				{op: "failure", want: Closed},
+				{op: "success", want: Closed},
+				{op: "failure", want: Closed},
+				{op: "success", want: Closed},
+				{op: "failure", want: Open},
+			},
+		},
+		{
+			name: "probe success closes",
+			cfg:  Config{ConsecutiveFailures: 1, ProbeInterval: probeInterval},
+			steps: []step{
+				{op: "failure", want: Open},
+				{op: "allow", err: ErrOpen, want: Open},
+				{op: "wait", want: Open},
+				{op: "allow", want: HalfOpen},
+				{op: "allow", err: ErrOpen, want: HalfOpen},
+				{op: "success", want: Closed},
+				{op: "allow", want: Closed},
+			},
+		},
+		{
+			name: "probe failure reopens",
+			cfg:  Config{ConsecutiveFailures: 1, ProbeInterval: probeInterval},
+			steps: []step{
+				{op: "failure", want: Open},
+				{op: "wait", want: Open},
+				{op: "allow", want: HalfOpen},
+				{op: "failure", want: Open},
+				{op: "allow", err: ErrOpen, want: Open},
+			},
+		},
+		{
+			name: "probe failure after DisableAfter disables",
+			cfg:  Config{ConsecutiveFailures: 1, ProbeInterval: probeInterval, DisableAfter: probeInterval},
+			steps: []step{
+				{op: "failure", want: Open},
+				{op: "wait", want: Open},
+				{op: "allow", want: HalfOpen},
+				{op: "failure", want: Disabled},
+				{op: "allow", err: ErrDisabled, want: Disabled},
+				{op: "success", want: Disabled},
+				{op: "reset", want: Closed},
+				{op: "allow", want: Closed},
+			},
+		},
+		{
+			name: "negative DisableAfter never disables",
+			cfg:  Config{ConsecutiveFailures: 1, ProbeInterval: probeInterval, DisableAfter: -1},
+			steps: []step{
+				{op: "failure", want: Open},
+				{op: "wait", want: Open},
+				{op: "allow", want: HalfOpen},
+				{op: "failure", want: Open},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.cfg)
+			for i, step := range tt.steps {
+				switch step.op {
+				case "allow":
+					if err := b.Allow(); !errors.Is(err, step.err) {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, err, step.err)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure("failed")
+				case "wait":
+					time.Sleep(2 * probeInterval)
+				case "reset":
+					b.Reset()
+				}
+				if got := b.Status().State; got != step.want {
+					t.Fatalf("step %d: state after %s = %s, want %s", i, step.op, got, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerLateFailureKeepsProbe(t *testing.T) {
+	b := New(Config{ConsecutiveFailures: 1, ProbeInterval: time.Hour})
+	b.Failure("failed")
+	probeAt := b.Status().NextProbeAt
+
+	// A request sent before the circuit opened fails afterwards
+	time.Sleep(time.Millisecond)
+	b.Failure("failed late")
+
+	s := b.Status()
+	if s.State != Open {
+		t.Fatalf("state = %s, want open", s.State)
+	}
+	if !s.NextProbeAt.Equal(probeAt) {
+		t.Errorf("NextProbeAt = %s, want %s", s.NextProbeAt, probeAt)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	b := New(Config{})
+	if b.cfg != DefaultConfig() {
+		t.Errorf("config = %+v, want %+v", b.cfg, DefaultConfig())
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+)
+
+// WithAttemptLog records every attempt in store: the request headers without credentials,
+// the SHA-256 hash of the body, the response status and truncated body, the latency
+// and the error, if any. endpoint identifies the endpoint in the records;
+// if it is empty, the target URL is used.
+// Attempts rejected by WithBreaker or WithSafeDialer are recorded too.
+func WithAttemptLog(store attemptlog.Store, endpoint string) Option {
+	return func(wc *WebhookClient) {
+		wc.attemptLog = store
+		wc.endpointID = endpoint
+	}
+}
+
+// WithAttemptBodies records the full request body of every attempt, not only its hash.
+// It has no effect without WithAttemptLog.
+func WithAttemptBodies() Option {
+	return func(wc *WebhookClient) {
+		wc.logBodies = true
+	}
+}
+
+// logAttempt adds the outcome of an attempt to the attempt log.
+// sentHeader and sentAt are zero if the request was never sent.
+func (c *WebhookClient) logAttempt(ctx context.Context, msgID string, body []byte, sentHeader http.Header, sentAt time.Time, res *response, err error) {
+	sum := sha256.Sum256(body)
+	a := &attemptlog.Attempt{
+		MsgID:             msgID,
+		Endpoint:          c.endpointID,
+		URL:               c.targetURL,
+		EventType:         attemptEventType(sentHeader, body),
+		At:                sentAt,
+		RequestBodySHA256: hex.EncodeToString(sum[:]),
+	}
+	if a.Endpoint == "" {
+		a.Endpoint = c.targetURL
+	}
+	if sentAt.IsZero() {
+		a.At = time.Now()
+	} else {
+		a.Latency = time.Since(sentAt)
+		a.RequestHeaders = attemptlog.RedactHeaders(sentHeader)
+	}
+	if c.logBodies {
+		a.RequestBody = string(body)
+	}
+
+	var se *UnexpectedStatusError
+	switch {
+	case res != nil:
+		a.StatusCode = res.StatusCode
+		a.ResponseBody = attemptlog.TruncateBody(res.Body)
+	case errors.As(err, &se):
+		a.StatusCode = se.StatusCode
+		a.ResponseBody = attemptlog.TruncateBody(se.Body)
+	}
+	if err != nil {
+		a.ErrorClass = errorType(err)
+		a.Error = err.Error()
+	}
+
+	// The attempt log must not make deliveries fail.
+	_ = c.attemptLog.Add(context.WithoutCancel(ctx), a)
+}
+
+// attemptEventType returns the type of the event sent, or "" for a batch.
+func attemptEventType(header http.Header, body []byte) string {
+	if t := header.Get(cloudevents.HeaderPrefix + "type"); t != "" {
+		return t
+	}
+	return rawEventType(body)
+}
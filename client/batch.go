@@ -0,0 +1,76 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+)
+
+// SendBatch sends several events in one userEventBatch request: a JSON array signed as a whole
+// with msgID as webhook-id. Each event is stamped and validated as in SendWebhook.
+// The receiver handles every event on its own, so a successful response may still
+// report failed events in its results, in the order of events.
+func (c *WebhookClient) SendBatch(ctx context.Context, msgID string, events []*api.WebhookEvent) (api.UserEventBatchRes, error) {
+	bodies := make([][]byte, len(events))
+	for i, event := range events {
+		body, err := c.Encode(event)
+		if err != nil {
+			return nil, err
+		}
+		bodies[i] = body
+	}
+
+	return c.SendBatchRaw(ctx, msgID, bodies)
+}
+
+// SendBatchRaw sends already encoded events in one batch request, like SendRaw does for a single event.
+// With WithCloudEvents, the batch is sent as an application/cloudevents-batch+json array.
+func (c *WebhookClient) SendBatchRaw(ctx context.Context, msgID string, events [][]byte) (api.UserEventBatchRes, error) {
+	if len(events) == 0 {
+		return nil, errors.New("client: empty batch")
+	}
+
+	body := append(append([]byte{'['}, bytes.Join(events, []byte{','})...), ']')
+	header := http.Header{"Content-Type": {"application/json"}}
+	if c.cloudEvents != 0 {
+		var err error
+		if body, header, err = cloudevents.EncodeBatch(events, c.cloudEventsSource); err != nil {
+			return nil, err
+		}
+	}
+
+	spanAttrs := []attribute.KeyValue{BatchSizeKey.Int(len(events))}
+	return sendWithRetry(ctx, c, userEventBatchOperation, nil, spanAttrs, msgID, body, header, decodeBatchResponse)
+}
+
+// decodeBatchResponse decodes the response to a userEventBatch request based on status code.
+func decodeBatchResponse(resp *response) (api.UserEventBatchRes, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var result api.BatchResponse
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	case http.StatusBadRequest:
+		var result api.UserEventBatchBadRequest
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	case http.StatusUnauthorized:
+		var result api.UserEventBatchUnauthorized
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	default:
+		return nil, &UnexpectedStatusError{StatusCode: resp.StatusCode, Body: resp.Body}
+	}
+}
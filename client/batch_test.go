@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+)
+
+// deletingHandler fails user.deleted events and counts the events it handles.
+type deletingHandler struct {
+	receiver.BaseEventHandler
+	calls atomic.Int32
+}
+
+func (h *deletingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.calls.Add(1)
+	return nil
+}
+
+func (h *deletingHandler) UserDeleted(ctx context.Context, event *api.UserDeletedEvent) error {
+	h.calls.Add(1)
+	return errors.New("failed")
+}
+
+// newTestReceiver starts a receiver with a replay store calling h, and returns its URL and secret.
+func newTestReceiver(t *testing.T, h receiver.EventHandler, opts ...receiver.Option) (string, string) {
+	t.Helper()
+	secret := testSecret(t)
+	opts = append([]receiver.Option{receiver.WithReplayStore(receiver.NewMemoryReplayStore(100, time.Hour))}, opts...)
+	rc, err := receiver.NewWithSecret(secret, receiver.Typed(h), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	return srv.URL, secret
+}
+
+func TestSendBatch(t *testing.T) {
+	h := &deletingHandler{}
+	url, secret := newTestReceiver(t, h)
+	c, err := NewWebhookClient(url, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := testEvent()
+	deleted := NewUserDeletedEvent(api.UserDeletedData{ID: "user_1"})
+	res, err := c.SendBatch(context.Background(), "msg_batch", []*api.WebhookEvent{created, deleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBatchResults(t, res, []api.BatchEventResult{
+		{ID: EventID(created), Success: true},
+		{ID: EventID(deleted), Success: false, Error: api.NewOptString(http.StatusText(http.StatusInternalServerError))},
+	})
+}
+
+func TestSendBatchDuplicate(t *testing.T) {
+	h := &deletingHandler{}
+	url, secret := newTestReceiver(t, h)
+	c, err := NewWebhookClient(url, secret, WithRetryPolicy(DefaultRetryPolicy()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []*api.WebhookEvent{testEvent(), testEvent()}
+	want := []api.BatchEventResult{
+		{ID: EventID(events[0]), Success: true},
+		{ID: EventID(events[1]), Success: true},
+	}
+	// Sending the batch again with the same webhook-id, as after a lost response, is a duplicate
+	for range 2 {
+		res, err := c.SendBatch(context.Background(), "msg_batch", events)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkBatchResults(t, res, want)
+	}
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+}
+
+// checkBatchResults checks that res is a BatchResponse with the results want.
+func checkBatchResults(t *testing.T, res api.UserEventBatchRes, want []api.BatchEventResult) {
+	t.Helper()
+	br, ok := res.(*api.BatchResponse)
+	if !ok {
+		t.Fatalf("response = %T, want *api.BatchResponse", res)
+	}
+	if len(br.Results) != len(want) {
+		t.Fatalf("%d results, want %d", len(br.Results), len(want))
+	}
+	for i := range want {
+		if br.Results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, br.Results[i], want[i])
+		}
+	}
+}
+
+func TestSendBatchErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response testResponse
+		want     api.UserEventBatchRes
+	}{
+		{name: "bad request", response: badRequestResponse, want: &api.UserEventBatchBadRequest{Error: "bad request"}},
+		{name: "unauthorized", response: unauthorizedResponse, want: &api.UserEventBatchUnauthorized{Error: "invalid signature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.response)
+			c, err := NewWebhookClient(srv.URL, testSecret(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := c.SendBatch(context.Background(), "msg_batch", []*api.WebhookEvent{testEvent()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch got := res.(type) {
+			case *api.UserEventBatchBadRequest:
+				if want, ok := tt.want.(*api.UserEventBatchBadRequest); !ok || *got != *want {
+					t.Errorf("response = %+v, want %+v", got, tt.want)
+				}
+			case *api.UserEventBatchUnauthorized:
+				if want, ok := tt.want.(*api.UserEventBatchUnauthorized); !ok || *got != *want {
+					t.Errorf("response = %+v, want %+v", got, tt.want)
+				}
+			default:
+				t.Errorf("response = %T, want %T", res, tt.want)
+			}
+		})
+	}
+
+	c, err := NewWebhookClient("http://127.0.0.1:1", testSecret(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendBatch(context.Background(), "msg_batch", nil); err == nil {
+		t.Error("SendBatch sent an empty batch")
+	}
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
+)
+
+// WithRecorder appends every request sent to rec, in the capture format cmd/replay reads:
+// the request headers without credentials, the raw body, and the response, also without
+// credentials, or the error.
+// Attempts WithBreaker rejects are not recorded, since no request was made.
+// Errors of the recorder are ignored, so recording never makes a delivery fail.
+func WithRecorder(rec *capture.Recorder) Option {
+	return func(wc *WebhookClient) {
+		wc.recorder = rec
+	}
+}
+
+// record appends a sent request and its outcome to the recorder.
+// respHeader is nil if there was no response.
+func (c *WebhookClient) record(body []byte, sentHeader http.Header, sentAt time.Time, respHeader http.Header, res *response, err error) {
+	rec := &capture.Record{
+		Time:    sentAt,
+		URL:     c.targetURL,
+		Headers: attemptlog.RedactHeaders(sentHeader),
+		Body:    string(body),
+	}
+	if respHeader != nil {
+		rec.Response = &capture.Response{
+			Headers:   attemptlog.RedactHeaders(respHeader),
+			LatencyMS: float64(time.Since(sentAt)) / float64(time.Millisecond),
+		}
+		var se *UnexpectedStatusError
+		switch {
+		case res != nil:
+			rec.Response.Status = res.StatusCode
+			rec.Response.Body = string(res.Body)
+		case errors.As(err, &se):
+			rec.Response.Status = se.StatusCode
+			rec.Response.Body = string(se.Body)
+		}
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	_ = c.recorder.Record(rec)
+}
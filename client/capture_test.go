@@ -0,0 +1,59 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
+)
+
+func TestRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret; HttpOnly")
+		io.WriteString(w, okResponse.body)
+	}))
+	t.Cleanup(srv.Close)
+
+	var buf bytes.Buffer
+	c, err := NewWebhookClient(srv.URL, testSecret(t), WithRecorder(capture.NewRecorder(&buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := testEvent()
+	if _, err := c.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*capture.Record
+	err = capture.Read(&buf, func(_ int, rec *capture.Record) error {
+		records = append(records, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("%d records, want 1", len(records))
+	}
+	rec := records[0]
+	if rec.URL != srv.URL || rec.MsgID() == "" || rec.Headers.Get("webhook-signature") == "" {
+		t.Errorf("record = %+v, want the signed request to %s", rec, srv.URL)
+	}
+	if rec.Response == nil {
+		t.Fatal("response not recorded")
+	}
+	if rec.Response.Status != http.StatusOK || rec.Response.Body != okResponse.body {
+		t.Errorf("response = %d %q, want 200 %q", rec.Response.Status, rec.Response.Body, okResponse.body)
+	}
+	if v := rec.Response.Headers.Get("Set-Cookie"); v != "" {
+		t.Errorf("Set-Cookie = %q, want it redacted", v)
+	}
+	if v := rec.Response.Headers.Get("Content-Type"); v != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", v)
+	}
+}
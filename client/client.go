@@ -3,17 +3,34 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
-	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
+	"github.com/naoyafurudono/hello-std-webhooks/circuit"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+	"github.com/naoyafurudono/hello-std-webhooks/ratelimit"
+	"github.com/naoyafurudono/hello-std-webhooks/schema"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
 )
 
+// ErrNoMessageID is returned when sending without a webhook-id,
+// for example because a MessageIDFunc returned "".
+var ErrNoMessageID = errors.New("client: webhook-id is required")
+
 // Default HTTP client with reasonable timeout settings.
 var defaultHTTPClient = &http.Client{
 	Timeout: 30 * time.Second,
@@ -30,6 +47,73 @@ func WithHTTPClient(client *http.Client) Option {
 	}
 }
 
+// Validator checks an event before it is sent.
+// *schema.Validator satisfies this interface.
+type Validator interface {
+	Validate(event *api.WebhookEvent) error
+}
+
+var _ Validator = (*schema.Validator)(nil)
+
+// WithValidator validates every event before it is signed and sent.
+// An invalid event is not sent and the validator's error is returned,
+// such as a *schema.ValidationError listing every violation.
+func WithValidator(v Validator) Option {
+	return func(wc *WebhookClient) {
+		wc.validator = v
+	}
+}
+
+// WithProducer sets the producer of events which don't name one.
+func WithProducer(producer string) Option {
+	return func(wc *WebhookClient) {
+		wc.producer = producer
+	}
+}
+
+// WithCloudEvents sends events as CloudEvents 1.0 in binary or structured mode.
+// The CloudEvent source is the event's producer, or source if it has none.
+// Signatures cover the exact bytes sent, so receivers verify them as usual.
+func WithCloudEvents(mode cloudevents.Mode, source string) Option {
+	return func(wc *WebhookClient) {
+		wc.cloudEvents = mode
+		wc.cloudEventsSource = source
+	}
+}
+
+// WithLimiter waits for the endpoint's rate and in-flight limits before each attempt.
+// 429 responses lower the limiter's rate, and successful ones let it recover.
+// Share one Limiter between all clients sending to the same endpoint.
+func WithLimiter(l *ratelimit.Limiter) Option {
+	return func(wc *WebhookClient) {
+		wc.limiter = l
+	}
+}
+
+// WithBreaker stops sending to the endpoint while its circuit is open or it is disabled,
+// failing attempts with a *circuit.OpenError or a *circuit.DisabledError instead.
+// Transport errors and 404, 408, 410 and 5xx responses count as failures, any other response as a success.
+// Share one Breaker between all clients sending to the same endpoint.
+func WithBreaker(b *circuit.Breaker) Option {
+	return func(wc *WebhookClient) {
+		wc.breaker = b
+	}
+}
+
+// WithSafeDialer makes every connection through d, which refuses to connect to
+// loopback, private, link-local and metadata addresses unless they are allowlisted.
+// It replaces the transport of the HTTP client, also one set with WithHTTPClient.
+// Attempts to a blocked address fail with a *netguard.BlockedError and are not retried.
+func WithSafeDialer(d *netguard.Dialer) Option {
+	return func(wc *WebhookClient) {
+		wc.dialer = d
+	}
+}
+
+// Signer signs webhook payloads and returns the webhook-signature header value.
+// *standardwebhooks.Webhook and the signers in the signing package satisfy this interface.
+type Signer = signing.Signer
+
 // WebhookClient sends webhook events with standard-webhooks signing.
 // Note: This client does not use ogen-generated WebhookClient because
 // we need to add standard-webhooks signature headers (webhook-id, webhook-timestamp,
@@ -37,46 +121,237 @@ func WithHTTPClient(client *http.Client) Option {
 // before signing. Using a custom HTTP client is simpler than using a RoundTripper
 // that needs to coordinate the message ID from the caller.
 type WebhookClient struct {
-	wh         *standardwebhooks.Webhook
+	signer     Signer
 	targetURL  string
 	httpClient *http.Client
+	retry      RetryPolicy
+	validator  Validator
+	producer   string
+	messageID  MessageIDFunc
+	limiter    *ratelimit.Limiter
+	breaker    *circuit.Breaker
+	dialer     *netguard.Dialer
+
+	attemptLog attemptlog.Store
+	endpointID string
+	logBodies  bool
+	recorder   *capture.Recorder
+
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	telemetry      *telemetry
+	propagator     propagation.TextMapPropagator
+
+	cloudEvents       cloudevents.Mode
+	cloudEventsSource string
 }
 
 // NewWebhookClient creates a new webhook client with signature signing capability.
-// The secret should be a base64-encoded secret key.
+// The secret should be a base64-encoded secret key (whsec_) for symmetric v1 signatures,
+// or an Ed25519 private key (whsk_) for asymmetric v1a signatures.
 func NewWebhookClient(targetURL string, secret string, opts ...Option) (*WebhookClient, error) {
-	wh, err := standardwebhooks.NewWebhook(secret)
+	signer, err := signing.NewSigner(secret)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewWebhookClientWithSigner(targetURL, signer, opts...), nil
+}
+
+// NewWebhookClientWithSigner creates a new webhook client which signs requests with signer.
+// Use it with a *signing.KeyRing to sign with several secrets during secret rotation.
+func NewWebhookClientWithSigner(targetURL string, signer Signer, opts ...Option) *WebhookClient {
 	wc := &WebhookClient{
-		wh:         wh,
+		signer:     signer,
 		targetURL:  targetURL,
 		httpClient: defaultHTTPClient,
+		propagator: defaultPropagator,
 	}
 
 	for _, opt := range opts {
 		opt(wc)
 	}
 
-	return wc, nil
+	if wc.dialer != nil {
+		hc := *wc.httpClient
+		hc.Transport = wc.dialer.Transport()
+		wc.httpClient = &hc
+	}
+	wc.telemetry = newTelemetry(wc.tracerProvider, wc.meterProvider, targetURL)
+
+	return wc
+}
+
+// Send sends a webhook event with its webhook-id derived from the event,
+// by default from the event ID, see WithMessageIDFunc.
+// Build events with NewUserCreatedEvent and friends, or Stamp them,
+// so that the event ID and hence the webhook-id are stable across retries.
+// If no webhook-id can be derived, ErrNoMessageID is returned and nothing is sent.
+func (c *WebhookClient) Send(ctx context.Context, event *api.WebhookEvent) (api.UserEventRes, error) {
+	event = Stamp(event, c.producer)
+	return c.SendWebhook(ctx, c.MessageID(event), event)
 }
 
 // SendWebhook sends a webhook event with proper standard-webhooks headers.
 // The msgID should be unique per event and remain the same across retries.
 // This is used as an idempotency key by consumers.
+//
+// If a retry policy is configured, failed attempts are retried with the same msgID.
+// Each attempt is signed again with a fresh webhook-timestamp.
 func (c *WebhookClient) SendWebhook(ctx context.Context, msgID string, event *api.WebhookEvent) (api.UserEventRes, error) {
-	// Encode the event to JSON
-	body, err := event.MarshalJSON()
+	body, err := c.Encode(event)
 	if err != nil {
 		return nil, err
 	}
 
+	return c.SendRaw(ctx, msgID, body)
+}
+
+// Encode returns the body SendWebhook would send for event: missing envelope
+// fields are filled in as by Stamp, with the client's producer, and the event
+// is validated if the client has a Validator.
+func (c *WebhookClient) Encode(event *api.WebhookEvent) ([]byte, error) {
+	event = Stamp(event, c.producer)
+
+	// Reject invalid events before anything is signed
+	if c.validator != nil {
+		if err := c.validator.Validate(event); err != nil {
+			return nil, err
+		}
+	}
+
+	// Encode the event to JSON
+	return event.MarshalJSON()
+}
+
+// SendRaw sends an already encoded event, such as one converted to another schema version,
+// with the same signing and retries as SendWebhook. The body is not validated; it is sent
+// as is, or as a CloudEvent if the client was created WithCloudEvents.
+func (c *WebhookClient) SendRaw(ctx context.Context, msgID string, body []byte) (api.UserEventRes, error) {
+	var attrs []attribute.KeyValue
+	if eventType := rawEventType(body); eventType != "" {
+		attrs = append(attrs, EventTypeKey.String(eventType))
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if c.cloudEvents != 0 {
+		var err error
+		if body, header, err = cloudevents.Encode(body, c.cloudEvents, c.cloudEventsSource); err != nil {
+			return nil, err
+		}
+	}
+
+	return sendWithRetry(ctx, c, userEventOperation, attrs, nil, msgID, body, header, decodeEventResponse)
+}
+
+// rawEventType returns the type of a JSON-encoded event, or "" if it has none.
+func rawEventType(body []byte) string {
+	var event struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(body, &event)
+	return event.Type
+}
+
+// sendWithRetry delivers an encoded body, retrying as configured by the client's retry policy,
+// and decodes the response with decode. The delivery is traced as op, see startDelivery for the attributes.
+func sendWithRetry[R any](ctx context.Context, c *WebhookClient, op operation, attrs, spanAttrs []attribute.KeyValue, msgID string, body []byte, header http.Header, decode func(*response) (R, error)) (_ R, err error) {
+	// Receivers deduplicate by webhook-id, so an empty one would make every delivery look alike
+	if msgID == "" {
+		var zero R
+		return zero, ErrNoMessageID
+	}
+
+	ctx, dt := c.telemetry.startDelivery(ctx, op, msgID, attrs, spanAttrs)
+	defer func() {
+		dt.end(ctx, err)
+	}()
+
+	var zero R
+	maxAttempts := c.retry.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		res, err := c.send(ctx, msgID, body, header)
+		dt.attemptDone(ctx, statusCode(res, err), err)
+		if err == nil {
+			return decode(res)
+		}
+		if attempt >= maxAttempts || !Retryable(err) || ctx.Err() != nil {
+			return zero, err
+		}
+
+		delay := c.retry.Backoff(attempt)
+		if d, ok := RetryAfter(err); ok {
+			// Give up rather than retry earlier than the server asked us to.
+			if c.retry.MaxDelay > 0 && d > c.retry.MaxDelay {
+				return zero, err
+			}
+			delay = d
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return zero, err
+		}
+	}
+}
+
+// statusCode returns the status code of an attempt, or 0 if there was no response.
+func statusCode(res *response, err error) int {
+	if res != nil {
+		return res.StatusCode
+	}
+	var se *UnexpectedStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
+
+// response is a response with a status code the webhook operations define.
+type response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// send makes a single delivery attempt of an encoded body with the given content headers.
+// Responses with a status code which no webhook operation defines are returned as *UnexpectedStatusError.
+func (c *WebhookClient) send(ctx context.Context, msgID string, body []byte, header http.Header) (res *response, err error) {
+	// Record the attempt, whatever its outcome
+	var (
+		sentAt     time.Time
+		sentHeader http.Header
+		respHeader http.Header
+	)
+	if c.attemptLog != nil {
+		defer func() {
+			c.logAttempt(ctx, msgID, body, sentHeader, sentAt, res, err)
+		}()
+	}
+	if c.recorder != nil {
+		defer func() {
+			if !sentAt.IsZero() {
+				c.record(body, sentHeader, sentAt, respHeader, res, err)
+			}
+		}()
+	}
+
+	// Fail fast rather than queue up for an endpoint which is down
+	if c.breaker != nil {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wait for the endpoint's limits, so that excess requests queue up here
+	if c.limiter != nil {
+		release, err := c.limiter.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
 	timestamp := time.Now()
 
 	// Sign the payload
-	signature, err := c.wh.Sign(msgID, timestamp, body)
+	signature, err := c.signer.Sign(msgID, timestamp, body)
 	if err != nil {
 		return nil, err
 	}
@@ -88,52 +363,110 @@ func (c *WebhookClient) SendWebhook(ctx context.Context, msgID string, event *ap
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	req.Header.Set("webhook-id", msgID)
 	req.Header.Set("webhook-timestamp", formatTimestamp(timestamp))
 	req.Header.Set("webhook-signature", signature)
+	if c.propagator != nil {
+		// The delivery span in ctx becomes the parent of the receiver's span
+		c.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
 
 	// Send the request
+	sentAt, sentHeader = time.Now(), req.Header
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		var be *netguard.BlockedError
+		if errors.As(err, &be) {
+			return nil, err
+		}
+		c.reportFailure(ctx, err.Error())
+		return nil, &transportError{err: err}
 	}
 	defer resp.Body.Close()
+	respHeader = resp.Header
 
 	// Read the response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
+		c.reportFailure(ctx, err.Error())
+		return nil, &transportError{err: err}
+	}
+
+	if c.breaker != nil {
+		if breakerFailure(resp.StatusCode) {
+			c.breaker.Failure(resp.Status)
+		} else {
+			c.breaker.Success()
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized:
+		if c.limiter != nil && resp.StatusCode == http.StatusOK {
+			c.limiter.Succeeded()
+		}
+		return &response{StatusCode: resp.StatusCode, Body: respBody}, nil
+	default:
+		err := &UnexpectedStatusError{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}
+		if c.limiter != nil && resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter, _ := RetryAfter(err)
+			c.limiter.Throttled(retryAfter)
+		}
 		return nil, err
 	}
+}
+
+// reportFailure records a failed attempt in the breaker,
+// unless it failed because ctx was canceled rather than because of the endpoint.
+func (c *WebhookClient) reportFailure(ctx context.Context, reason string) {
+	if c.breaker != nil && ctx.Err() == nil {
+		c.breaker.Failure(reason)
+	}
+}
+
+// breakerFailure reports whether a response status means the endpoint is failing.
+func breakerFailure(code int) bool {
+	switch code {
+	case http.StatusNotFound, http.StatusRequestTimeout, http.StatusGone:
+		return true
+	default:
+		return code >= 500
+	}
+}
 
-	// Decode the response based on status code
+// decodeEventResponse decodes the response to a userEvent request based on status code.
+func decodeEventResponse(resp *response) (api.UserEventRes, error) {
 	switch resp.StatusCode {
 	case http.StatusOK:
 		var result api.WebhookResponse
-		if err := result.UnmarshalJSON(respBody); err != nil {
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
 			return nil, err
 		}
 		return &result, nil
 	case http.StatusBadRequest:
 		var result api.UserEventBadRequest
-		if err := result.UnmarshalJSON(respBody); err != nil {
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
 			return nil, err
 		}
 		return &result, nil
 	case http.StatusUnauthorized:
 		var result api.UserEventUnauthorized
-		if err := result.UnmarshalJSON(respBody); err != nil {
+		if err := result.UnmarshalJSON(resp.Body); err != nil {
 			return nil, err
 		}
 		return &result, nil
 	default:
-		return nil, &UnexpectedStatusError{StatusCode: resp.StatusCode, Body: respBody}
+		return nil, &UnexpectedStatusError{StatusCode: resp.StatusCode, Body: resp.Body}
 	}
 }
 
 // UnexpectedStatusError is returned when the server returns an unexpected HTTP status code.
 type UnexpectedStatusError struct {
 	StatusCode int
+	Header     http.Header
 	Body       []byte
 }
 
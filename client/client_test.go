@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+// testResponse is a response of a testServer.
+type testResponse struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+// testServer answers requests with its responses in turn, repeating the last one,
+// and records the requests.
+type testServer struct {
+	*httptest.Server
+	responses []testResponse
+
+	mu       sync.Mutex
+	requests []*recordedRequest
+}
+
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestServer(t *testing.T, responses ...testResponse) *testServer {
+	t.Helper()
+	s := &testServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, &recordedRequest{header: r.Header.Clone(), body: body})
+		res := s.responses[min(len(s.requests), len(s.responses))-1]
+		s.mu.Unlock()
+
+		if res.retryAfter != "" {
+			w.Header().Set("Retry-After", res.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(res.status)
+		io.WriteString(w, res.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received returns the requests received so far.
+func (s *testServer) received() []*recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*recordedRequest(nil), s.requests...)
+}
+
+var (
+	okResponse           = testResponse{status: http.StatusOK, body: `{"success":true,"message":"ok"}`}
+	badRequestResponse   = testResponse{status: http.StatusBadRequest, body: `{"error":"bad request"}`}
+	unauthorizedResponse = testResponse{status: http.StatusUnauthorized, body: `{"error":"invalid signature"}`}
+)
+
+// testSecret returns a new whsec_ secret.
+func testSecret(t *testing.T) string {
+	t.Helper()
+	secret, err := signing.GenerateSecret(signing.DefaultSecretBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func testEvent() *api.WebhookEvent {
+	return NewUserCreatedEvent(api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"})
+}
+
+// verifyRequest checks the signature of a recorded request with secret.
+func verifyRequest(t *testing.T, secret string, req *recordedRequest) {
+	t.Helper()
+	verifier, err := signing.NewVerifier(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.Verify(req.body, req.header); err != nil {
+		t.Errorf("request with webhook-timestamp %s: %v", req.header.Get("webhook-timestamp"), err)
+	}
+}
+
+func TestSendWebhookHeaders(t *testing.T) {
+	srv := newTestServer(t, okResponse)
+	secret := testSecret(t)
+	c, err := NewWebhookClient(srv.URL, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := testEvent()
+	res, err := c.Send(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.(*api.WebhookResponse); !ok {
+		t.Fatalf("response = %T, want *api.WebhookResponse", res)
+	}
+
+	reqs := srv.received()
+	if len(reqs) != 1 {
+		t.Fatalf("%d requests, want 1", len(reqs))
+	}
+	if got, want := reqs[0].header.Get("webhook-id"), MessageID(event); got != want {
+		t.Errorf("webhook-id = %q, want %q", got, want)
+	}
+	if _, err := strconv.ParseInt(reqs[0].header.Get("webhook-timestamp"), 10, 64); err != nil {
+		t.Errorf("webhook-timestamp: %v", err)
+	}
+	verifyRequest(t, secret, reqs[0])
+}
+
+func TestSendWithoutMessageID(t *testing.T) {
+	srv := newTestServer(t, okResponse)
+	secret := testSecret(t)
+	noID := WithMessageIDFunc(func(*api.WebhookEvent, string) string { return "" })
+	c, err := NewWebhookClient(srv.URL, secret, noID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.Send(ctx, testEvent()); !errors.Is(err, ErrNoMessageID) {
+		t.Errorf("Send() = %v, want %v", err, ErrNoMessageID)
+	}
+	if _, err := c.SendWebhook(ctx, "", testEvent()); !errors.Is(err, ErrNoMessageID) {
+		t.Errorf("SendWebhook() = %v, want %v", err, ErrNoMessageID)
+	}
+	if _, err := c.SendBatch(ctx, "", []*api.WebhookEvent{testEvent()}); !errors.Is(err, ErrNoMessageID) {
+		t.Errorf("SendBatch() = %v, want %v", err, ErrNoMessageID)
+	}
+	if n := len(srv.received()); n != 0 {
+		t.Errorf("%d requests sent without a webhook-id", n)
+	}
+}
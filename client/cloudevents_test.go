@@ -0,0 +1,135 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+)
+
+// capturingHandler keeps the user.created events it handles.
+type capturingHandler struct {
+	receiver.BaseEventHandler
+	mu     sync.Mutex
+	events []*api.UserCreatedEvent
+}
+
+func (h *capturingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	return nil
+}
+
+func TestSendCloudEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        cloudevents.Mode
+		contentType string
+	}{
+		{name: "binary", mode: cloudevents.Binary, contentType: "application/json"},
+		{name: "structured", mode: cloudevents.Structured, contentType: cloudevents.ContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := testSecret(t)
+			h := &capturingHandler{}
+			rc, err := receiver.NewWithSecret(secret, receiver.Typed(h), receiver.WithCloudEvents())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Keep the request as it went over the wire before the receiver handles it
+			var sent *recordedRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				sent = &recordedRequest{header: r.Header.Clone(), body: body}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				rc.ServeHTTP(w, r)
+			}))
+			t.Cleanup(srv.Close)
+
+			c, err := NewWebhookClient(srv.URL, secret, WithCloudEvents(tt.mode, "/users"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			occurred := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			event := NewUserCreatedEvent(api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"},
+				WithOccurredAt(occurred), WithTenant("tenant_1"))
+			res, err := c.Send(context.Background(), event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, ok := res.(*api.WebhookResponse); !ok || !r.Success {
+				t.Fatalf("response = %+v, want success", res)
+			}
+
+			// The signature covers the CloudEvents body, not the webhook event it was mapped from
+			if ct := sent.header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+			if tt.mode == cloudevents.Binary && sent.header.Get("ce-id") != EventID(event) {
+				t.Errorf("ce-id = %q, want %q", sent.header.Get("ce-id"), EventID(event))
+			}
+			verifyRequest(t, secret, sent)
+
+			if len(h.events) != 1 {
+				t.Fatalf("%d events handled, want 1", len(h.events))
+			}
+			got := h.events[0]
+			switch {
+			case got.ID != EventID(event):
+				t.Errorf("ID = %q, want %q", got.ID, EventID(event))
+			case !got.Timestamp.Equal(occurred):
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, occurred)
+			case got.Producer.Or("") != "/users":
+				t.Errorf("Producer = %q, want the CloudEvents source /users", got.Producer.Or(""))
+			case got.Tenant.Or("") != "tenant_1":
+				t.Errorf("Tenant = %q, want tenant_1", got.Tenant.Or(""))
+			case got.Version.Or(0) != UserCreatedVersion:
+				t.Errorf("Version = %d, want %d", got.Version.Or(0), UserCreatedVersion)
+			case got.Data != (api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"}):
+				t.Errorf("Data = %+v, want the data sent", got.Data)
+			}
+		})
+	}
+}
+
+func TestSendCloudEventsTampered(t *testing.T) {
+	// A receiver rejects a CloudEvents body which differs from the bytes signed
+	secret := testSecret(t)
+	h := &capturingHandler{}
+	rc, err := receiver.NewWithSecret(secret, receiver.Typed(h), receiver.WithCloudEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		body = bytes.Replace(body, []byte("user@example.com"), []byte("evil@example.com"), 1)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		rc.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewWebhookClient(srv.URL, secret, WithCloudEvents(cloudevents.Structured, "/users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Send(context.Background(), testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.(*api.UserEventUnauthorized); !ok {
+		t.Errorf("response = %T, want *api.UserEventUnauthorized", res)
+	}
+	if len(h.events) != 0 {
+		t.Errorf("%d events handled, want 0", len(h.events))
+	}
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// Schema versions of the events built by this package, as described by api/openapi.yaml.
+// Bump a version when the shape of its data changes, and register the
+// transforms from the previous version in a versioning.Registry.
+const (
+	UserCreatedVersion = 1
+	UserUpdatedVersion = 1
+	UserDeletedVersion = 1
+
+	EndpointVerificationVersion = 1
+)
+
+// EventIDPrefix is the prefix of event IDs generated by NewEventID.
+const EventIDPrefix = "evt_"
+
+// NewEventID returns a new unique event ID.
+func NewEventID() string {
+	return EventIDPrefix + uuid.New().String()
+}
+
+// EventID returns the ID of the event, or "" if it has none.
+func EventID(event *api.WebhookEvent) string {
+	env := envelopeOf(event)
+	if env == nil {
+		return ""
+	}
+	return env.GetID()
+}
+
+// MessageID returns the webhook-id for an event, derived from its ID,
+// so that every delivery of the event shares the same idempotency key.
+// It returns "" for an event without an ID.
+func MessageID(event *api.WebhookEvent) string {
+	env := envelopeOf(event)
+	if env == nil || env.GetID() == "" {
+		return ""
+	}
+	return "msg_" + strings.TrimPrefix(env.GetID(), EventIDPrefix)
+}
+
+// EventOption sets envelope fields of an event built by this package.
+type EventOption func(envelope)
+
+// WithEventID sets the event ID instead of generating a new one.
+// Use it to build the same logical event again, e.g. when re-running a job.
+func WithEventID(id string) EventOption {
+	return func(env envelope) {
+		env.SetID(id)
+	}
+}
+
+// WithOccurredAt sets when the event occurred instead of the current time.
+func WithOccurredAt(t time.Time) EventOption {
+	return func(env envelope) {
+		env.SetTimestamp(t)
+	}
+}
+
+// WithEventProducer sets the service which produced the event.
+func WithEventProducer(producer string) EventOption {
+	return func(env envelope) {
+		env.SetProducer(api.NewOptString(producer))
+	}
+}
+
+// WithTenant sets the tenant the event belongs to.
+func WithTenant(tenant string) EventOption {
+	return func(env envelope) {
+		env.SetTenant(api.NewOptString(tenant))
+	}
+}
+
+// NewUserCreatedEvent returns a user.created event carrying data,
+// with a new event ID and the current time as its occurrence.
+func NewUserCreatedEvent(data api.UserCreatedData, opts ...EventOption) *api.WebhookEvent {
+	event := api.NewUserCreatedEventWebhookEvent(api.UserCreatedEvent{
+		Type:    string(api.UserCreatedEventWebhookEvent),
+		Version: api.NewOptInt(UserCreatedVersion),
+		Data:    data,
+	})
+	return newEvent(&event, opts)
+}
+
+// NewUserUpdatedEvent returns a user.updated event carrying data,
+// with a new event ID and the current time as its occurrence.
+func NewUserUpdatedEvent(data api.UserUpdatedData, opts ...EventOption) *api.WebhookEvent {
+	event := api.NewUserUpdatedEventWebhookEvent(api.UserUpdatedEvent{
+		Type:    string(api.UserUpdatedEventWebhookEvent),
+		Version: api.NewOptInt(UserUpdatedVersion),
+		Data:    data,
+	})
+	return newEvent(&event, opts)
+}
+
+// NewUserDeletedEvent returns a user.deleted event carrying data,
+// with a new event ID and the current time as its occurrence.
+func NewUserDeletedEvent(data api.UserDeletedData, opts ...EventOption) *api.WebhookEvent {
+	event := api.NewUserDeletedEventWebhookEvent(api.UserDeletedEvent{
+		Type:    string(api.UserDeletedEventWebhookEvent),
+		Version: api.NewOptInt(UserDeletedVersion),
+		Data:    data,
+	})
+	return newEvent(&event, opts)
+}
+
+// NewEndpointVerificationEvent returns an endpoint.verification event carrying challenge,
+// which the endpoint must echo back to prove its owner controls it.
+func NewEndpointVerificationEvent(challenge string, opts ...EventOption) *api.WebhookEvent {
+	event := api.NewEndpointVerificationEventWebhookEvent(api.EndpointVerificationEvent{
+		Type:    string(api.EndpointVerificationEventWebhookEvent),
+		Version: api.NewOptInt(EndpointVerificationVersion),
+		Data:    api.EndpointVerificationData{Challenge: challenge},
+	})
+	return newEvent(&event, opts)
+}
+
+func newEvent(event *api.WebhookEvent, opts []EventOption) *api.WebhookEvent {
+	env := envelopeOf(event)
+	env.SetID(NewEventID())
+	env.SetTimestamp(time.Now().UTC())
+	for _, opt := range opts {
+		opt(env)
+	}
+	return event
+}
+
+// Stamp returns a copy of event whose missing envelope fields are filled in:
+// a new event ID, the current time, and producer if it is not empty.
+// Events built by this package already have an ID and a time; stamping other events
+// once before sending keeps the event ID stable across retries and endpoints.
+func Stamp(event *api.WebhookEvent, producer string) *api.WebhookEvent {
+	stamped := *event
+	env := envelopeOf(&stamped)
+	if env == nil {
+		return &stamped
+	}
+	if env.GetID() == "" {
+		env.SetID(NewEventID())
+	}
+	if env.GetTimestamp().IsZero() {
+		env.SetTimestamp(time.Now().UTC())
+	}
+	if producer != "" && !env.GetProducer().IsSet() {
+		env.SetProducer(api.NewOptString(producer))
+	}
+	return &stamped
+}
+
+// envelope is implemented by every event variant.
+type envelope interface {
+	GetID() string
+	SetID(string)
+	GetTimestamp() time.Time
+	SetTimestamp(time.Time)
+	GetProducer() api.OptString
+	SetProducer(api.OptString)
+	GetTenant() api.OptString
+	SetTenant(api.OptString)
+}
+
+// envelopeOf returns the variant of event holding its envelope fields,
+// or nil for an unknown event type.
+func envelopeOf(event *api.WebhookEvent) envelope {
+	switch event.Type {
+	case api.UserCreatedEventWebhookEvent:
+		return &event.UserCreatedEvent
+	case api.UserUpdatedEventWebhookEvent:
+		return &event.UserUpdatedEvent
+	case api.UserDeletedEventWebhookEvent:
+		return &event.UserDeletedEvent
+	case api.EndpointVerificationEventWebhookEvent:
+		return &event.EndpointVerificationEvent
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// MessageIDNamespace is the default UUID namespace for UUIDv5MessageID.
+var MessageIDNamespace = uuid.MustParse("ceb4b3e6-cd01-4ef0-a675-c85c1d248ef4")
+
+// MessageIDFunc derives the webhook-id of an event sent to an endpoint,
+// given as the endpoint ID or its URL.
+// It must return the same ID whenever the same event is sent to the same endpoint,
+// because receivers drop deliveries whose webhook-id they have already seen.
+// An empty ID makes the delivery fail with ErrNoMessageID.
+type MessageIDFunc func(event *api.WebhookEvent, endpoint string) string
+
+// WithMessageIDFunc sets how Send derives the webhook-id, with the target URL as the endpoint.
+// The default only depends on the event ID, see the MessageID function.
+func WithMessageIDFunc(fn MessageIDFunc) Option {
+	return func(wc *WebhookClient) {
+		wc.messageID = fn
+	}
+}
+
+// UUIDv5MessageID returns a MessageIDFunc deriving a name-based UUID (version 5)
+// from the event's tenant, its event ID and the endpoint within namespace.
+// Re-sending the same logical event to the same endpoint always yields the same webhook-id,
+// while other tenants and endpoints get different ones.
+func UUIDv5MessageID(namespace uuid.UUID) MessageIDFunc {
+	return func(event *api.WebhookEvent, endpoint string) string {
+		env := envelopeOf(event)
+		if env == nil || env.GetID() == "" {
+			return ""
+		}
+		name := strings.Join([]string{env.GetTenant().Or(""), env.GetID(), endpoint}, "\x00")
+		return "msg_" + uuid.NewSHA1(namespace, []byte(name)).String()
+	}
+}
+
+// MessageID returns the webhook-id Send uses for event.
+func (c *WebhookClient) MessageID(event *api.WebhookEvent) string {
+	if c.messageID != nil {
+		return c.messageID(event, c.targetURL)
+	}
+	return MessageID(event)
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+)
+
+// tracingHandler keeps the span context and baggage of the events it handles.
+type tracingHandler struct {
+	receiver.BaseEventHandler
+	mu      sync.Mutex
+	span    trace.SpanContext
+	baggage baggage.Baggage
+}
+
+func (h *tracingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.span = trace.SpanContextFromContext(ctx)
+	h.baggage = baggage.FromContext(ctx)
+	return nil
+}
+
+func TestTracePropagation(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientOpts   []Option
+		receiverOpts []receiver.Option
+		propagated   bool
+	}{
+		{name: "propagated", propagated: true},
+		{name: "client without propagation", clientOpts: []Option{WithoutTracePropagation()}},
+		{name: "receiver without propagation", receiverOpts: []receiver.Option{receiver.WithoutTracePropagation()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Sender and receiver export to the same exporter, as if they reported to the same backend
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			secret := testSecret(t)
+			h := &tracingHandler{}
+			opts := append([]receiver.Option{receiver.WithServerOptions(api.WithTracerProvider(tp))}, tt.receiverOpts...)
+			rc, err := receiver.NewWithSecret(secret, receiver.Typed(h), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(rc)
+			t.Cleanup(srv.Close)
+
+			c, err := NewWebhookClient(srv.URL, secret, append([]Option{WithTracerProvider(tp)}, tt.clientOpts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			member, err := baggage.NewMember("tenant", "tenant_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			bag, err := baggage.New(member)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, caller := tp.Tracer("test").Start(baggage.ContextWithBaggage(context.Background(), bag), "caller")
+			if _, err := c.Send(ctx, testEvent()); err != nil {
+				t.Fatal(err)
+			}
+			caller.End()
+
+			var delivery, server tracetest.SpanStub
+			for _, s := range exporter.GetSpans() {
+				switch s.SpanKind {
+				case trace.SpanKindClient:
+					delivery = s
+				case trace.SpanKindServer:
+					server = s
+				}
+			}
+			if !delivery.SpanContext.IsValid() || !server.SpanContext.IsValid() {
+				t.Fatalf("spans = %+v, want a client and a server span", exporter.GetSpans())
+			}
+			if delivery.Parent.SpanID() != caller.SpanContext().SpanID() {
+				t.Errorf("delivery span parent = %s, want the caller's span %s", delivery.Parent.SpanID(), caller.SpanContext().SpanID())
+			}
+			// The handler runs within the span of the generated server
+			if h.span.SpanID() != server.SpanContext.SpanID() {
+				t.Errorf("handler span = %s, want the server span %s", h.span.SpanID(), server.SpanContext.SpanID())
+			}
+
+			if !tt.propagated {
+				if server.Parent.IsValid() || server.SpanContext.TraceID() == delivery.SpanContext.TraceID() {
+					t.Errorf("server span continues the trace %s of the sender", server.SpanContext.TraceID())
+				}
+				if h.baggage.Len() != 0 {
+					t.Errorf("handler baggage = %s, want none", h.baggage)
+				}
+				return
+			}
+			if !server.Parent.IsRemote() || server.Parent.SpanID() != delivery.SpanContext.SpanID() {
+				t.Errorf("server span parent = %s, want the remote delivery span %s", server.Parent.SpanID(), delivery.SpanContext.SpanID())
+			}
+			if server.SpanContext.TraceID() != delivery.SpanContext.TraceID() {
+				t.Errorf("server span trace = %s, want %s", server.SpanContext.TraceID(), delivery.SpanContext.TraceID())
+			}
+			if v := h.baggage.Member("tenant").Value(); v != "tenant_1" {
+				t.Errorf("handler baggage tenant = %q, want tenant_1", v)
+			}
+		})
+	}
+}
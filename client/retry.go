@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how SendWebhook retries failed deliveries.
+//
+// Only transport errors and retryable status codes (5xx, 408 and 429) are retried.
+// Responses that decode into api.UserEventBadRequest or api.UserEventUnauthorized
+// are never retried because sending the same request again cannot succeed.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	// Values less than 1 are treated as 1 (no retries).
+	MaxAttempts int
+	// BaseDelay is the upper bound of the delay before the first retry.
+	// The upper bound doubles on every subsequent retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. Zero means no cap,
+	// so the delay keeps doubling up to the longest time.Duration.
+	// A Retry-After value larger than MaxDelay stops retrying.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns a retry policy with reasonable defaults.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// WithRetryPolicy sets the retry policy for the WebhookClient.
+// Without this option SendWebhook makes exactly one attempt.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(wc *WebhookClient) {
+		wc.retry = policy
+	}
+}
+
+// Backoff returns the delay before the given retry, where retry 1 follows the first attempt.
+// It uses "full jitter": a random duration between zero and the exponential upper bound.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if p.BaseDelay <= 0 || retry < 1 {
+		return 0
+	}
+
+	ceiling := p.BaseDelay
+	for i := 1; i < retry; i++ {
+		// Stop doubling once the cap is reached, or before the bound overflows
+		if (p.MaxDelay > 0 && ceiling >= p.MaxDelay) || ceiling > math.MaxInt64/2 {
+			break
+		}
+		ceiling *= 2
+	}
+	if p.MaxDelay > 0 && ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+
+	return rand.N(min(ceiling, math.MaxInt64-1) + 1)
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Retryable reports whether err, as returned by SendWebhook, is worth retrying.
+func Retryable(err error) bool {
+	var te *transportError
+	if errors.As(err, &te) {
+		return true
+	}
+	var se *UnexpectedStatusError
+	if errors.As(err, &se) {
+		return retryableStatus(se.StatusCode)
+	}
+	return false
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of a 429 or 503 response.
+func RetryAfter(err error) (time.Duration, bool) {
+	var se *UnexpectedStatusError
+	if !errors.As(err, &se) {
+		return 0, false
+	}
+	if se.StatusCode != http.StatusTooManyRequests && se.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	return parseRetryAfter(se.Header.Get("Retry-After"), time.Now())
+}
+
+func retryableStatus(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either
+// a number of seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// transportError marks a failure to send the request or to read the response.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		max    time.Duration
+	}{
+		{name: "no base delay", policy: RetryPolicy{}, retry: 3, max: 0},
+		{name: "before the first attempt", policy: RetryPolicy{BaseDelay: time.Second}, retry: 0, max: 0},
+		{name: "first retry", policy: RetryPolicy{BaseDelay: time.Second}, retry: 1, max: time.Second},
+		{name: "doubles", policy: RetryPolicy{BaseDelay: time.Second}, retry: 3, max: 4 * time.Second},
+		{name: "capped", policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, retry: 10, max: 5 * time.Second},
+		{name: "capped large retry", policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, retry: math.MaxInt, max: 5 * time.Second},
+		{name: "uncapped retry 35", policy: RetryPolicy{BaseDelay: time.Second}, retry: 35, max: math.MaxInt64},
+		{name: "uncapped retry 64", policy: RetryPolicy{BaseDelay: time.Second}, retry: 64, max: math.MaxInt64},
+		{name: "uncapped large retry", policy: RetryPolicy{BaseDelay: time.Second}, retry: math.MaxInt, max: math.MaxInt64},
+		{name: "longest base delay", policy: RetryPolicy{BaseDelay: math.MaxInt64}, retry: 2, max: math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				if d := tt.policy.Backoff(tt.retry); d < 0 || d > tt.max {
+					t.Fatalf("Backoff(%d) = %s, want between 0 and %s", tt.retry, d, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "3", want: 3 * time.Second, wantOK: true},
+		{value: "0", want: 0, wantOK: true},
+		{value: "-1", wantOK: false},
+		{value: "soon", wantOK: false},
+		{value: now.Add(time.Minute).Format(http.TimeFormat), want: time.Minute, wantOK: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSendWebhookRetries(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		responses []testResponse
+		attempts  int
+		// want is the type of the response, or nil if an *UnexpectedStatusError is expected
+		want api.UserEventRes
+	}{
+		{
+			name:      "retries server errors",
+			policy:    policy,
+			responses: []testResponse{{status: 500}, {status: 502}, okResponse},
+			attempts:  3,
+			want:      &api.WebhookResponse{},
+		},
+		{
+			name:      "retries timeouts",
+			policy:    policy,
+			responses: []testResponse{{status: http.StatusRequestTimeout}, okResponse},
+			attempts:  2,
+			want:      &api.WebhookResponse{},
+		},
+		{
+			name:      "gives up after MaxAttempts",
+			policy:    policy,
+			responses: []testResponse{{status: 503}},
+			attempts:  3,
+		},
+		{
+			name:      "no retry without a policy",
+			responses: []testResponse{{status: 500}, okResponse},
+			attempts:  1,
+		},
+		{
+			name:      "no retry on 400",
+			policy:    policy,
+			responses: []testResponse{badRequestResponse, okResponse},
+			attempts:  1,
+			want:      &api.UserEventBadRequest{},
+		},
+		{
+			name:      "no retry on 401",
+			policy:    policy,
+			responses: []testResponse{unauthorizedResponse, okResponse},
+			attempts:  1,
+			want:      &api.UserEventUnauthorized{},
+		},
+		{
+			name:      "no retry on other client errors",
+			policy:    policy,
+			responses: []testResponse{{status: http.StatusNotFound}, okResponse},
+			attempts:  1,
+		},
+		{
+			name:      "gives up when Retry-After exceeds MaxDelay",
+			policy:    policy,
+			responses: []testResponse{{status: http.StatusTooManyRequests, retryAfter: "60"}, okResponse},
+			attempts:  1,
+		},
+		{
+			name:      "retries after Retry-After",
+			policy:    policy,
+			responses: []testResponse{{status: http.StatusTooManyRequests, retryAfter: "0"}, okResponse},
+			attempts:  2,
+			want:      &api.WebhookResponse{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.responses...)
+			c, err := NewWebhookClient(srv.URL, testSecret(t), WithRetryPolicy(tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.Send(context.Background(), testEvent())
+			if n := len(srv.received()); n != tt.attempts {
+				t.Errorf("%d attempts, want %d", n, tt.attempts)
+			}
+			if tt.want == nil {
+				var se *UnexpectedStatusError
+				if !errors.As(err, &se) {
+					t.Errorf("Send() = %v, %v, want an *UnexpectedStatusError", res, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.TypeOf(res) != reflect.TypeOf(tt.want) {
+				t.Errorf("response = %T, want %T", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWebhookRetryAfterSignsAgain(t *testing.T) {
+	srv := newTestServer(t, testResponse{status: http.StatusServiceUnavailable, retryAfter: "1"}, okResponse)
+	secret := testSecret(t)
+	c, err := NewWebhookClient(srv.URL, secret, WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := c.Send(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want to wait for Retry-After", elapsed)
+	}
+
+	reqs := srv.received()
+	if len(reqs) != 2 {
+		t.Fatalf("%d attempts, want 2", len(reqs))
+	}
+	first, second := reqs[0].header, reqs[1].header
+	if first.Get("webhook-id") != second.Get("webhook-id") {
+		t.Errorf("webhook-id changed from %s to %s", first.Get("webhook-id"), second.Get("webhook-id"))
+	}
+	t1, _ := strconv.ParseInt(first.Get("webhook-timestamp"), 10, 64)
+	t2, _ := strconv.ParseInt(second.Get("webhook-timestamp"), 10, 64)
+	if t2 <= t1 {
+		t.Errorf("webhook-timestamp of the retry = %d, want later than %d", t2, t1)
+	}
+	for _, req := range reqs {
+		verifyRequest(t, secret, req)
+	}
+}
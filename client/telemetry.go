@@ -0,0 +1,239 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/ogen-go/ogen/otelogen"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/circuit"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+)
+
+// Name of the instrumentation scope of WebhookClient spans and metrics.
+const instrumentationName = "github.com/naoyafurudono/hello-std-webhooks/client"
+
+// Attributes of WebhookClient spans and metrics, in addition to the oas.* attributes
+// of otelogen and the semconv HTTP attributes used by the generated code.
+const (
+	EventTypeKey = attribute.Key("webhook.event.type") // Type of the event, for single events
+	MessageIDKey = attribute.Key("webhook.id")         // webhook-id of the delivery, on spans only
+	AttemptKey   = attribute.Key("webhook.attempt")    // Number of the attempt, starting at 1
+	OutcomeKey   = attribute.Key("webhook.outcome")    // "delivered", "rejected" or "failed"
+	BatchSizeKey = attribute.Key("webhook.batch.size") // Number of events in a batch, on spans only
+)
+
+// Outcomes of a delivery, as values of OutcomeKey.
+const (
+	OutcomeDelivered = "delivered" // The endpoint accepted the event
+	OutcomeRejected  = "rejected"  // The endpoint answered 400 or 401
+	OutcomeFailed    = "failed"    // No known response, even after retries
+)
+
+// Metrics of WebhookClient deliveries.
+const (
+	DeliveryCount    = "webhook.client.delivery_count"    // Deliveries total, by outcome
+	AttemptCount     = "webhook.client.attempt_count"     // Attempts total, by status code
+	DeliveryDuration = "webhook.client.delivery.duration" // End to end duration including retries, milliseconds
+)
+
+// WithTracerProvider sets the tracer provider creating a client span for every delivery.
+// If none is specified, the otel.GetTracerProvider() is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(wc *WebhookClient) {
+		if provider != nil {
+			wc.tracerProvider = provider
+		}
+	}
+}
+
+// WithMeterProvider sets the meter provider recording delivery metrics.
+// If none is specified, the otel.GetMeterProvider() is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(wc *WebhookClient) {
+		if provider != nil {
+			wc.meterProvider = provider
+		}
+	}
+}
+
+// WithoutTracePropagation stops injecting the W3C traceparent, tracestate and baggage
+// headers into requests. Use it for untrusted third-party endpoints, which should not
+// learn trace IDs or baggage of the caller.
+// By default the trace context and baggage of the context passed to SendWebhook are sent.
+func WithoutTracePropagation() Option {
+	return func(wc *WebhookClient) {
+		wc.propagator = nil
+	}
+}
+
+// defaultPropagator injects W3C trace context and baggage.
+var defaultPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// telemetry holds the tracer and instruments of a WebhookClient.
+type telemetry struct {
+	tracer     trace.Tracer
+	deliveries metric.Int64Counter
+	attempts   metric.Int64Counter
+	duration   metric.Float64Histogram
+	// server are the attributes of the endpoint
+	server []attribute.KeyValue
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider, targetURL string) *telemetry {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+	fallback := metricnoop.Meter{}
+
+	t := &telemetry{tracer: tp.Tracer(instrumentationName)}
+	var err error
+	if t.deliveries, err = meter.Int64Counter(DeliveryCount,
+		metric.WithDescription("Webhook deliveries total"),
+		metric.WithUnit("{count}"),
+	); err != nil {
+		otel.Handle(err)
+		t.deliveries, _ = fallback.Int64Counter(DeliveryCount)
+	}
+	if t.attempts, err = meter.Int64Counter(AttemptCount,
+		metric.WithDescription("Webhook delivery attempts total"),
+		metric.WithUnit("{count}"),
+	); err != nil {
+		otel.Handle(err)
+		t.attempts, _ = fallback.Int64Counter(AttemptCount)
+	}
+	if t.duration, err = meter.Float64Histogram(DeliveryDuration,
+		metric.WithDescription("Webhook delivery end to end duration, including retries"),
+		metric.WithUnit("ms"),
+	); err != nil {
+		otel.Handle(err)
+		t.duration, _ = fallback.Float64Histogram(DeliveryDuration)
+	}
+
+	if u, err := url.Parse(targetURL); err == nil && u.Hostname() != "" {
+		t.server = append(t.server, semconv.ServerAddress(u.Hostname()))
+	}
+	return t
+}
+
+// operation is a webhook of the OpenAPI document.
+type operation struct {
+	name api.OperationName
+	id   string
+}
+
+var (
+	userEventOperation      = operation{name: api.UserEventOperation, id: "userEvent"}
+	userEventBatchOperation = operation{name: api.UserEventBatchOperation, id: "userEventBatch"}
+)
+
+// deliveryTrace records the span and metrics of one delivery.
+type deliveryTrace struct {
+	t         *telemetry
+	span      trace.Span
+	attrs     []attribute.KeyValue // attributes of the metrics
+	startTime time.Time
+	attempt   int
+	status    int
+}
+
+// startDelivery starts the client span of a delivery. attrs describe its payload on the span
+// and the metrics, spanAttrs only on the span.
+func (t *telemetry) startDelivery(ctx context.Context, op operation, msgID string, attrs, spanAttrs []attribute.KeyValue) (context.Context, *deliveryTrace) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID(op.id),
+		otelogen.WebhookName(op.id),
+	}
+	otelAttrs = append(otelAttrs, t.server...)
+	otelAttrs = append(otelAttrs, attrs...)
+
+	ctx, span := t.tracer.Start(ctx, op.name,
+		trace.WithAttributes(otelAttrs...),
+		trace.WithAttributes(MessageIDKey.String(msgID)),
+		trace.WithAttributes(spanAttrs...),
+		trace.WithSpanKind(trace.SpanKindClient),
+	)
+	return ctx, &deliveryTrace{t: t, span: span, attrs: otelAttrs, startTime: time.Now()}
+}
+
+// attemptDone records the outcome of an attempt: its status code, or 0 if there was no response.
+func (d *deliveryTrace) attemptDone(ctx context.Context, status int, err error) {
+	d.attempt++
+	d.status = status
+
+	attrs := []attribute.KeyValue{AttemptKey.Int(d.attempt)}
+	if status != 0 {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(status))
+	}
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+	}
+	d.span.AddEvent("attempt", trace.WithAttributes(attrs...))
+
+	metricAttrs := d.attrs
+	if status != 0 {
+		metricAttrs = append(metricAttrs[:len(metricAttrs):len(metricAttrs)], semconv.HTTPResponseStatusCode(status))
+	}
+	d.t.attempts.Add(ctx, 1, metric.WithAttributes(metricAttrs...))
+}
+
+// end ends the span and records the delivery, which failed if err is not nil.
+func (d *deliveryTrace) end(ctx context.Context, err error) {
+	outcome := OutcomeDelivered
+	switch {
+	case err != nil:
+		outcome = OutcomeFailed
+	case d.status != 200:
+		outcome = OutcomeRejected
+	}
+
+	d.span.SetAttributes(AttemptKey.Int(d.attempt), OutcomeKey.String(outcome))
+	if d.status != 0 {
+		d.span.SetAttributes(semconv.HTTPResponseStatusCode(d.status))
+	}
+	if err != nil {
+		d.span.RecordError(err)
+		d.span.SetStatus(codes.Error, outcome)
+	}
+	d.span.End()
+
+	attrs := metric.WithAttributes(append(d.attrs[:len(d.attrs):len(d.attrs)], OutcomeKey.String(outcome))...)
+	d.t.deliveries.Add(ctx, 1, attrs)
+	// Use floating point division here for higher precision (instead of Millisecond method).
+	d.t.duration.Record(ctx, float64(time.Since(d.startTime))/float64(time.Millisecond), attrs)
+}
+
+// errorType classifies an attempt error for the error.type attribute.
+func errorType(err error) string {
+	var se *UnexpectedStatusError
+	var te *transportError
+	switch {
+	case errors.As(err, &se):
+		return "status"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.Is(err, circuit.ErrOpen), errors.Is(err, circuit.ErrDisabled):
+		return "circuit_open"
+	case errors.As(err, new(*netguard.BlockedError)):
+		return "blocked"
+	case errors.As(err, &te):
+		return "transport"
+	default:
+		return "other"
+	}
+}
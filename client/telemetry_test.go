@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// int64Points returns the data points of the int64 sum named name, by their value of key.
+func int64Points(t *testing.T, rm metricdata.ResourceMetrics, name string, key attribute.Key) map[string]int64 {
+	t.Helper()
+	points := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("%s is %T, want a sum", name, m.Data)
+			}
+			for _, dp := range sum.DataPoints {
+				v, _ := dp.Attributes.Value(key)
+				points[v.Emit()] += dp.Value
+			}
+		}
+	}
+	return points
+}
+
+// histogramCount returns the number of values recorded in the float64 histogram named name.
+func histogramCount(rm metricdata.ResourceMetrics, name string) uint64 {
+	var n uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == name {
+				for _, dp := range h.DataPoints {
+					n += dp.Count
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestTelemetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []testResponse
+		outcome   string
+		status    int
+		// attempts are the attempts made by status code
+		attempts map[string]int64
+	}{
+		{
+			name:      "delivered after a retry",
+			responses: []testResponse{{status: http.StatusServiceUnavailable}, okResponse},
+			outcome:   OutcomeDelivered,
+			status:    http.StatusOK,
+			attempts:  map[string]int64{"503": 1, "200": 1},
+		},
+		{
+			name:      "rejected",
+			responses: []testResponse{badRequestResponse},
+			outcome:   OutcomeRejected,
+			status:    http.StatusBadRequest,
+			attempts:  map[string]int64{"400": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+			reader := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+			srv := newTestServer(t, tt.responses...)
+			c, err := NewWebhookClient(srv.URL, testSecret(t),
+				WithTracerProvider(tp),
+				WithMeterProvider(mp),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			event := testEvent()
+			if _, err := c.Send(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+
+			ended := spans.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("%d spans, want 1", len(ended))
+			}
+			span := ended[0]
+			if span.Name() != api.UserEventOperation || span.SpanKind() != trace.SpanKindClient {
+				t.Errorf("span %s of kind %s, want a client span %s", span.Name(), span.SpanKind(), api.UserEventOperation)
+			}
+			u, _ := url.Parse(srv.URL)
+			want := map[attribute.Key]string{
+				EventTypeKey:                      "user.created",
+				MessageIDKey:                      MessageID(event),
+				semconv.ServerAddressKey:          u.Hostname(),
+				AttemptKey:                        strconv.Itoa(len(tt.attempts)),
+				OutcomeKey:                        tt.outcome,
+				semconv.HTTPResponseStatusCodeKey: strconv.Itoa(tt.status),
+			}
+			got := map[attribute.Key]string{}
+			for _, kv := range span.Attributes() {
+				got[kv.Key] = kv.Value.Emit()
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("span attribute %s = %q, want %q", k, got[k], v)
+				}
+			}
+			if n := len(span.Events()); n != len(tt.attempts) {
+				t.Errorf("%d span events, want one per attempt", n)
+			}
+
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &rm); err != nil {
+				t.Fatal(err)
+			}
+			deliveries := int64Points(t, rm, DeliveryCount, OutcomeKey)
+			if len(deliveries) != 1 || deliveries[tt.outcome] != 1 {
+				t.Errorf("%s = %v, want one %s", DeliveryCount, deliveries, tt.outcome)
+			}
+			attempts := int64Points(t, rm, AttemptCount, semconv.HTTPResponseStatusCodeKey)
+			if len(attempts) != len(tt.attempts) {
+				t.Errorf("%s = %v, want %v", AttemptCount, attempts, tt.attempts)
+			}
+			for status, n := range tt.attempts {
+				if attempts[status] != n {
+					t.Errorf("%s = %v, want %v", AttemptCount, attempts, tt.attempts)
+				}
+			}
+			if n := histogramCount(rm, DeliveryDuration); n != 1 {
+				t.Errorf("%s has %d values, want 1", DeliveryDuration, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Package cloudevents maps webhook events to CloudEvents 1.0 HTTP messages and back,
+// in binary mode (ce-* headers with the data as body) or structured mode
+// (the whole event as an application/cloudevents+json body).
+//
+// The mapping works on JSON-encoded api.WebhookEvent values:
+//
+//	WebhookEvent  CloudEvent
+//	id            id
+//	type          type
+//	timestamp     time
+//	producer      source
+//	tenant        tenant (extension)
+//	version       schemaversion (extension)
+//	data          data
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Mode is how a CloudEvent is put into an HTTP message.
+type Mode int
+
+const (
+	// Binary mode carries the attributes in ce-* headers and the data as the body.
+	Binary Mode = iota + 1
+	// Structured mode carries the whole event as an application/cloudevents+json body.
+	Structured
+)
+
+const (
+	// SpecVersion is the CloudEvents version produced and accepted.
+	SpecVersion = "1.0"
+	// ContentType is the media type of structured mode messages.
+	ContentType = "application/cloudevents+json"
+	// BatchContentType is the media type of a batch of structured mode events.
+	BatchContentType = "application/cloudevents-batch+json"
+	// HeaderPrefix is the prefix of binary mode attribute headers.
+	HeaderPrefix = "ce-"
+)
+
+// event is a CloudEvent in structured mode.
+type event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Tenant          string          `json:"tenant,omitempty"`
+	SchemaVersion   *int            `json:"schemaversion,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// webhookEvent is the part of an encoded api.WebhookEvent which is mapped.
+type webhookEvent struct {
+	ID        string          `json:"id"`
+	Timestamp string          `json:"timestamp"`
+	Producer  string          `json:"producer,omitempty"`
+	Tenant    string          `json:"tenant,omitempty"`
+	Type      string          `json:"type"`
+	Version   *int            `json:"version,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Encode maps a JSON-encoded webhook event to a CloudEvents HTTP message.
+// It returns the body and the headers to send, including Content-Type.
+// The source is the event's producer, or source if the event has none.
+func Encode(body []byte, mode Mode, source string) ([]byte, http.Header, error) {
+	var we webhookEvent
+	if err := json.Unmarshal(body, &we); err != nil {
+		return nil, nil, fmt.Errorf("cloudevents: decode event: %w", err)
+	}
+	ce := event{
+		SpecVersion:     SpecVersion,
+		ID:              we.ID,
+		Source:          source,
+		Type:            we.Type,
+		Time:            we.Timestamp,
+		DataContentType: "application/json",
+		Tenant:          we.Tenant,
+		SchemaVersion:   we.Version,
+		Data:            we.Data,
+	}
+	if we.Producer != "" {
+		ce.Source = we.Producer
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return nil, nil, errors.New("cloudevents: id, source and type are required")
+	}
+
+	header := make(http.Header)
+	switch mode {
+	case Binary:
+		header.Set("Content-Type", ce.DataContentType)
+		header.Set(HeaderPrefix+"specversion", ce.SpecVersion)
+		header.Set(HeaderPrefix+"id", ce.ID)
+		header.Set(HeaderPrefix+"source", ce.Source)
+		header.Set(HeaderPrefix+"type", ce.Type)
+		if ce.Time != "" {
+			header.Set(HeaderPrefix+"time", ce.Time)
+		}
+		if ce.Tenant != "" {
+			header.Set(HeaderPrefix+"tenant", ce.Tenant)
+		}
+		if ce.SchemaVersion != nil {
+			header.Set(HeaderPrefix+"schemaversion", strconv.Itoa(*ce.SchemaVersion))
+		}
+		return ce.Data, header, nil
+	case Structured:
+		out, err := json.Marshal(ce)
+		if err != nil {
+			return nil, nil, err
+		}
+		header.Set("Content-Type", ContentType)
+		return out, header, nil
+	default:
+		return nil, nil, fmt.Errorf("cloudevents: unknown mode %d", mode)
+	}
+}
+
+// IsCloudEvent reports whether an HTTP message is a CloudEvent in either mode, or a batch of them.
+func IsCloudEvent(header http.Header) bool {
+	if header.Get(HeaderPrefix+"specversion") != "" {
+		return true
+	}
+	mt, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mt == ContentType || mt == BatchContentType
+}
+
+// Decode maps a CloudEvents HTTP message in either mode back to a JSON-encoded webhook event.
+// A CloudEvents batch is mapped to a JSON array of webhook events.
+// The event's producer is the CloudEvent source.
+func Decode(header http.Header, body []byte) ([]byte, error) {
+	if mt, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mt == BatchContentType {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, fmt.Errorf("cloudevents: decode batch: %w", err)
+		}
+		structured := http.Header{"Content-Type": {ContentType}}
+		for i, ce := range batch {
+			event, err := Decode(structured, ce)
+			if err != nil {
+				return nil, err
+			}
+			batch[i] = event
+		}
+		return json.Marshal(batch)
+	}
+
+	var ce event
+	if header.Get(HeaderPrefix+"specversion") != "" {
+		ce = event{
+			SpecVersion: header.Get(HeaderPrefix + "specversion"),
+			ID:          header.Get(HeaderPrefix + "id"),
+			Source:      header.Get(HeaderPrefix + "source"),
+			Type:        header.Get(HeaderPrefix + "type"),
+			Time:        header.Get(HeaderPrefix + "time"),
+			Tenant:      header.Get(HeaderPrefix + "tenant"),
+			Data:        body,
+		}
+		if v := header.Get(HeaderPrefix + "schemaversion"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("cloudevents: invalid schemaversion %q", v)
+			}
+			ce.SchemaVersion = &n
+		}
+		if mt, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mt != "" && mt != "application/json" && !strings.HasSuffix(mt, "+json") {
+			return nil, fmt.Errorf("cloudevents: unsupported data content type %q", mt)
+		}
+	} else if err := json.Unmarshal(body, &ce); err != nil {
+		return nil, fmt.Errorf("cloudevents: decode event: %w", err)
+	}
+
+	if ce.SpecVersion != SpecVersion {
+		return nil, fmt.Errorf("cloudevents: unsupported specversion %q", ce.SpecVersion)
+	}
+	if ce.ID == "" || ce.Source == "" || ce.Type == "" {
+		return nil, errors.New("cloudevents: id, source and type are required")
+	}
+
+	return json.Marshal(webhookEvent{
+		ID:        ce.ID,
+		Timestamp: ce.Time,
+		Producer:  ce.Source,
+		Tenant:    ce.Tenant,
+		Type:      ce.Type,
+		Version:   ce.SchemaVersion,
+		Data:      ce.Data,
+	})
+}
+
+// EncodeBatch maps JSON-encoded webhook events to a CloudEvents batch,
+// a JSON array of structured mode events.
+func EncodeBatch(events [][]byte, source string) ([]byte, http.Header, error) {
+	batch := make([]json.RawMessage, len(events))
+	for i, body := range events {
+		ce, _, err := Encode(body, Structured, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		batch[i] = ce
+	}
+	out, err := json.Marshal(batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", BatchContentType)
+	return out, header, nil
+}
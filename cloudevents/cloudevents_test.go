@@ -0,0 +1,184 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const (
+	userCreated = `{"id":"evt_1","timestamp":"2025-01-01T12:00:00Z","producer":"/users","tenant":"tenant_1","type":"user.created","version":2,"data":{"id":"user_1","email":"user@example.com"}}`
+	// minimal has no producer, tenant or version
+	minimal = `{"id":"evt_2","timestamp":"2025-01-01T12:00:00Z","type":"user.deleted","data":{"id":"user_1"}}`
+)
+
+// checkEvent checks that two JSON-encoded webhook events are equal.
+func checkEvent(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var g, w webhookEvent
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	gb, _ := json.Marshal(g)
+	wb, _ := json.Marshal(w)
+	if string(gb) != string(wb) {
+		t.Errorf("event = %s, want %s", gb, wb)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  string
+		source string
+		// want is the event decoded, whose producer is the source if it had none
+		want string
+	}{
+		{name: "every attribute", event: userCreated, source: "/default", want: userCreated},
+		{
+			name:   "default source",
+			event:  minimal,
+			source: "/default",
+			want:   `{"id":"evt_2","timestamp":"2025-01-01T12:00:00Z","producer":"/default","type":"user.deleted","data":{"id":"user_1"}}`,
+		},
+	}
+	for _, tt := range tests {
+		for _, m := range []struct {
+			name string
+			mode Mode
+		}{{"binary", Binary}, {"structured", Structured}} {
+			t.Run(tt.name+"/"+m.name, func(t *testing.T) {
+				body, header, err := Encode([]byte(tt.event), m.mode, tt.source)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !IsCloudEvent(header) {
+					t.Errorf("IsCloudEvent(%v) = false", header)
+				}
+				got, err := Decode(header, body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkEvent(t, got, tt.want)
+			})
+		}
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	body, header, err := Encode([]byte(userCreated), Binary, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"id":"user_1","email":"user@example.com"}`; string(body) != want {
+		t.Errorf("body = %s, want the data %s", body, want)
+	}
+	want := map[string]string{
+		"Content-Type":     "application/json",
+		"Ce-Specversion":   SpecVersion,
+		"Ce-Id":            "evt_1",
+		"Ce-Source":        "/users",
+		"Ce-Type":          "user.created",
+		"Ce-Time":          "2025-01-01T12:00:00Z",
+		"Ce-Tenant":        "tenant_1",
+		"Ce-Schemaversion": "2",
+	}
+	for k, v := range want {
+		if got := header.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestEncodeBatch(t *testing.T) {
+	body, header, err := EncodeBatch([][]byte{[]byte(userCreated), []byte(minimal)}, "/default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := header.Get("Content-Type"); ct != BatchContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, BatchContentType)
+	}
+	decoded, err := Decode(header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []json.RawMessage
+	if err := json.Unmarshal(decoded, &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d events, want 2", len(events))
+	}
+	checkEvent(t, events[0], userCreated)
+	checkEvent(t, events[1], `{"id":"evt_2","timestamp":"2025-01-01T12:00:00Z","producer":"/default","type":"user.deleted","data":{"id":"user_1"}}`)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	if _, _, err := Encode([]byte(minimal), Binary, ""); err == nil {
+		t.Error("Encode succeeded without a source")
+	}
+	if _, _, err := Encode([]byte(userCreated), Mode(0), ""); err == nil {
+		t.Error("Encode succeeded with an unknown mode")
+	}
+	if _, _, err := Encode([]byte(`[]`), Structured, "/default"); err == nil {
+		t.Error("Encode succeeded for a body which isn't an event")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	binary := func(kv ...string) http.Header {
+		h := http.Header{}
+		h.Set("Content-Type", "application/json")
+		h.Set("ce-specversion", SpecVersion)
+		h.Set("ce-id", "evt_1")
+		h.Set("ce-source", "/users")
+		h.Set("ce-type", "user.created")
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+	structured := http.Header{"Content-Type": {ContentType}}
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+	}{
+		{name: "binary spec version", header: binary("ce-specversion", "0.3"), body: `{}`},
+		{name: "binary without id", header: binary("ce-id", ""), body: `{}`},
+		{name: "binary schemaversion", header: binary("ce-schemaversion", "two"), body: `{}`},
+		{name: "binary data content type", header: binary("Content-Type", "text/plain"), body: `hello`},
+		{name: "structured spec version", header: structured, body: `{"specversion":"0.3","id":"evt_1","source":"/users","type":"user.created"}`},
+		{name: "structured without source", header: structured, body: `{"specversion":"1.0","id":"evt_1","type":"user.created"}`},
+		{name: "structured invalid", header: structured, body: `{`},
+		{name: "batch invalid", header: http.Header{"Content-Type": {BatchContentType}}, body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Decode(tt.header, []byte(tt.body)); err == nil {
+				t.Errorf("Decode() = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestIsCloudEvent(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   bool
+	}{
+		{header: http.Header{"Ce-Specversion": {SpecVersion}}, want: true},
+		{header: http.Header{"Content-Type": {ContentType + "; charset=utf-8"}}, want: true},
+		{header: http.Header{"Content-Type": {BatchContentType}}, want: true},
+		{header: http.Header{"Content-Type": {"application/json"}}, want: false},
+		{header: http.Header{}, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsCloudEvent(tt.header); got != tt.want {
+			t.Errorf("IsCloudEvent(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -2,18 +2,49 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/go-faster/jx"
-	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 
 	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
 	"github.com/naoyafurudono/hello-std-webhooks/client"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+	"github.com/naoyafurudono/hello-std-webhooks/outbox"
+	"github.com/naoyafurudono/hello-std-webhooks/schema"
 )
 
 func main() {
+	var (
+		outboxPath string
+		dlqPath    string
+		schemaPath string
+		eventID    string
+		tenant     string
+		ceMode     string
+		allow      string
+		noTrace    bool
+		attempts   string
+		record     string
+	)
+	flag.StringVar(&outboxPath, "outbox", "", "outbox file; if set, the event is stored there and delivered from it")
+	flag.StringVar(&dlqPath, "dlq", "", "dead letter file for permanently failed outbox messages")
+	flag.StringVar(&schemaPath, "schema", "", `validate the event before sending against "api" (the embedded OpenAPI document), an OpenAPI file, or a directory of JSON Schemas`)
+	flag.StringVar(&eventID, "event-id", "", "ID of the event; re-running with the same ID sends the same webhook-id, so receivers drop the duplicate")
+	flag.StringVar(&tenant, "tenant", "", "tenant the event belongs to")
+	flag.StringVar(&ceMode, "cloudevents", "", `send the event as a CloudEvent in "binary" or "structured" mode`)
+	flag.StringVar(&allow, "allow", "localhost,127.0.0.1,::1", "comma-separated hosts, IPs and CIDR ranges which may be sent to although they are not public")
+	flag.BoolVar(&noTrace, "no-trace-propagation", false, "don't send traceparent and baggage headers, e.g. to untrusted third-party endpoints")
+	flag.StringVar(&attempts, "attempt-log", "", "file to record every delivery attempt in, for 'webhookctl attempts'")
+	flag.StringVar(&record, "record", "", "file to append every request sent and its response to, for 'replay'")
+	flag.Parse()
+
 	// Load env.local if it exists (ignore error if not found)
 	_ = godotenv.Load("env.local")
 
@@ -27,26 +58,84 @@ func main() {
 		log.Fatal("WEBHOOK_SECRET is not set. Run 'make setup-env' first.")
 	}
 
-	// Create the webhook client
-	wc, err := client.NewWebhookClient(targetURL, secret)
+	// Refuse to send to internal addresses other than the allowed ones, such as the
+	// localhost URL written by 'make setup-env'
+	dialer, err := netguard.New(strings.Split(allow, ",")...)
+	if err != nil {
+		log.Fatalf("Invalid -allow: %v", err)
+	}
+
+	// Create the webhook client, retrying transient failures unless the outbox does
+	opts := []client.Option{client.WithSafeDialer(dialer)}
+	if noTrace {
+		opts = append(opts, client.WithoutTracePropagation())
+	}
+	if attempts != "" {
+		store, err := attemptlog.OpenFileStore(attempts)
+		if err != nil {
+			log.Fatalf("Failed to open attempt log: %v", err)
+		}
+		defer store.Close()
+		opts = append(opts, client.WithAttemptLog(store, ""))
+	}
+	if record != "" {
+		rec, err := capture.OpenRecorder(record)
+		if err != nil {
+			log.Fatalf("Failed to open capture file: %v", err)
+		}
+		defer rec.Close()
+		opts = append(opts, client.WithRecorder(rec))
+	}
+	if outboxPath == "" {
+		opts = append(opts, client.WithRetryPolicy(client.DefaultRetryPolicy()))
+	}
+	if schemaPath != "" {
+		v, err := loadValidator(schemaPath)
+		if err != nil {
+			log.Fatalf("Failed to load schemas: %v", err)
+		}
+		opts = append(opts, client.WithValidator(v))
+	}
+	switch ceMode {
+	case "":
+	case "binary":
+		opts = append(opts, client.WithCloudEvents(cloudevents.Binary, "/hello-std-webhooks/client"))
+	case "structured":
+		opts = append(opts, client.WithCloudEvents(cloudevents.Structured, "/hello-std-webhooks/client"))
+	default:
+		log.Fatalf("Unknown CloudEvents mode %q", ceMode)
+	}
+	opts = append(opts,
+		client.WithProducer("hello-std-webhooks/client"),
+		client.WithMessageIDFunc(client.UUIDv5MessageID(client.MessageIDNamespace)),
+	)
+	wc, err := client.NewWebhookClient(targetURL, secret, opts...)
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 
 	// Create a sample webhook event
-	event := &api.WebhookEvent{
-		Type: "user.created",
-		Data: api.WebhookEventData{
-			"id":    mustEncodeJSON("user_123"),
-			"email": mustEncodeJSON("user@example.com"),
-			"name":  mustEncodeJSON("John Doe"),
-		},
+	var eventOpts []client.EventOption
+	if eventID != "" {
+		eventOpts = append(eventOpts, client.WithEventID(eventID))
 	}
+	if tenant != "" {
+		eventOpts = append(eventOpts, client.WithTenant(tenant))
+	}
+	event := client.NewUserCreatedEvent(api.UserCreatedData{
+		ID:    "user_123",
+		Email: "user@example.com",
+		Name:  "John Doe",
+	}, eventOpts...)
+
+	// Derive the message ID from the tenant, event ID and target URL, so that it stays
+	// the same for every delivery of this event. Store the event for retries, see -outbox.
+	msgID := wc.MessageID(event)
 
-	// Generate a unique message ID for this event.
-	// In production, this should be derived from the event itself
-	// (e.g., "msg_" + event ID) and stored for retries.
-	msgID := "msg_" + uuid.New().String()
+	if outboxPath != "" {
+		sendViaOutbox(outboxPath, dlqPath, wc, msgID, event)
+		return
+	}
 
 	// Send the webhook with the message ID
 	log.Printf("Sending webhook to %s", targetURL)
@@ -68,8 +157,62 @@ func main() {
 	}
 }
 
-func mustEncodeJSON(v string) jx.Raw {
-	var e jx.Encoder
-	e.Str(v)
-	return e.Bytes()
+// loadValidator loads event schemas from the embedded OpenAPI document ("api"),
+// an OpenAPI file or a directory of JSON Schemas.
+func loadValidator(path string) (*schema.Validator, error) {
+	if path == "api" {
+		return schema.Default()
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return schema.LoadDir(path)
+	}
+	return schema.LoadOpenAPI(path)
+}
+
+// sendViaOutbox stores the event in the outbox and delivers every due message,
+// including messages left over from previous runs.
+func sendViaOutbox(path, dlqPath string, wc *client.WebhookClient, msgID string, event *api.WebhookEvent) {
+	ctx := context.Background()
+
+	store, err := outbox.OpenFileStore(path)
+	if err != nil {
+		log.Fatalf("Failed to open outbox: %v", err)
+	}
+	defer store.Close()
+
+	var opts []outbox.DispatcherOption
+	if dlqPath != "" {
+		dlq, err := outbox.OpenFileDeadLetterStore(dlqPath)
+		if err != nil {
+			log.Fatalf("Failed to open dead letter store: %v", err)
+		}
+		defer dlq.Close()
+		opts = append(opts, outbox.WithDeadLetterStore(dlq))
+	}
+
+	if _, err := store.Enqueue(ctx, msgID, event); err != nil {
+		log.Fatalf("Failed to enqueue webhook: %v", err)
+	}
+
+	log.Printf("Delivering outbox %s", path)
+	if err := outbox.NewDispatcher(store, wc, opts...).Drain(ctx); err != nil {
+		log.Fatalf("Failed to drain outbox: %v", err)
+	}
+
+	m, err := store.Get(ctx, msgID)
+	if err != nil {
+		log.Fatalf("Failed to read outbox: %v", err)
+	}
+	switch m.State {
+	case outbox.StateDelivered:
+		log.Printf("Webhook delivered: id=%s", m.ID)
+	case outbox.StatePending:
+		log.Printf("Webhook pending retry at %s: id=%s", m.NextAttemptAt.Format(time.RFC3339), m.ID)
+	case outbox.StateFailed:
+		log.Printf("Webhook failed: id=%s, error=%s", m.ID, m.Attempts[len(m.Attempts)-1].Error)
+	}
 }
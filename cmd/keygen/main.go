@@ -1,35 +1,39 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"flag"
 	"fmt"
 	"os"
-)
 
-const (
-	secretPrefix    = "whsec_"
-	defaultKeyBytes = 32 // 256 bits
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
 )
 
 func main() {
 	var (
 		keyBytes int
 		count    int
+		keyType  string
 	)
 
-	flag.IntVar(&keyBytes, "bytes", defaultKeyBytes, "key length in bytes (24-64)")
+	flag.IntVar(&keyBytes, "bytes", signing.DefaultSecretBytes, "key length in bytes (24-64, hmac only)")
 	flag.IntVar(&count, "n", 1, "number of keys to generate")
+	flag.StringVar(&keyType, "type", "hmac", "key type: hmac (whsec_) or ed25519 (prints \"whsk_... whpk_...\" per line)")
 	flag.Parse()
 
-	if keyBytes < 24 || keyBytes > 64 {
-		fmt.Fprintf(os.Stderr, "error: key length must be between 24 and 64 bytes\n")
+	switch keyType {
+	case "hmac":
+		if keyBytes < 24 || keyBytes > 64 {
+			fmt.Fprintf(os.Stderr, "error: key length must be between 24 and 64 bytes\n")
+			os.Exit(1)
+		}
+	case "ed25519":
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown key type %q\n", keyType)
 		os.Exit(1)
 	}
 
 	for i := 0; i < count; i++ {
-		key, err := generateKey(keyBytes)
+		key, err := generateKey(keyType, keyBytes)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: failed to generate key: %v\n", err)
 			os.Exit(1)
@@ -38,10 +42,15 @@ func main() {
 	}
 }
 
-func generateKey(bytes int) (string, error) {
-	buf := make([]byte, bytes)
-	if _, err := rand.Read(buf); err != nil {
-		return "", err
+// generateKey returns a whsec_ secret, or a whsk_ private key and
+// its whpk_ public key separated by a space.
+func generateKey(keyType string, bytes int) (string, error) {
+	if keyType == "ed25519" {
+		publicKey, privateKey, err := signing.GenerateKeyPair()
+		if err != nil {
+			return "", err
+		}
+		return privateKey + " " + publicKey, nil
 	}
-	return secretPrefix + base64.StdEncoding.EncodeToString(buf), nil
+	return signing.GenerateSecret(bytes)
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+)
+
+// handler logs every verified webhook event.
+type handler struct{}
+
+func (handler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	log.Printf("Received webhook (verified): id=%s, event=%s, type=%s, occurred=%s, user=%s, email=%s",
+		receiver.MessageID(ctx), event.ID, event.Type, event.Timestamp.Format(time.RFC3339), event.Data.ID, event.Data.Email)
+	return nil
+}
+
+func (handler) UserUpdated(ctx context.Context, event *api.UserUpdatedEvent) error {
+	log.Printf("Received webhook (verified): id=%s, event=%s, type=%s, occurred=%s, user=%s",
+		receiver.MessageID(ctx), event.ID, event.Type, event.Timestamp.Format(time.RFC3339), event.Data.ID)
+	return nil
+}
+
+func (handler) UserDeleted(ctx context.Context, event *api.UserDeletedEvent) error {
+	log.Printf("Received webhook (verified): id=%s, event=%s, type=%s, occurred=%s, user=%s",
+		receiver.MessageID(ctx), event.ID, event.Type, event.Timestamp.Format(time.RFC3339), event.Data.ID)
+	return nil
+}
+
+// Remember handled message IDs for twice the timestamp tolerance.
+const replayTTL = 10 * time.Minute
+
+func main() {
+	var (
+		addr       string
+		replayFile string
+		noTrace    bool
+	)
+	flag.StringVar(&addr, "addr", ":8080", "address to listen on")
+	flag.StringVar(&replayFile, "replay-store", "", "file to persist handled message IDs (default: in-memory)")
+	flag.BoolVar(&noTrace, "no-trace-propagation", false, "ignore traceparent and baggage headers of incoming webhooks")
+	flag.Parse()
+
+	// Load env.local if it exists (ignore error if not found)
+	_ = godotenv.Load("env.local")
+
+	// Verify with a public key (v1a) if configured, otherwise with the shared secret (v1)
+	secret := os.Getenv("WEBHOOK_PUBLIC_KEY")
+	if secret == "" {
+		secret = os.Getenv("WEBHOOK_SECRET")
+	}
+	if secret == "" {
+		log.Fatal("WEBHOOK_SECRET is not set. Run 'make setup-env' first.")
+	}
+
+	// Remember handled message IDs to reject replayed requests
+	var replay receiver.ReplayStore = receiver.NewMemoryReplayStore(10000, replayTTL)
+	if replayFile != "" {
+		fs, err := receiver.OpenFileReplayStore(replayFile, replayTTL)
+		if err != nil {
+			log.Fatalf("Failed to open replay store: %v", err)
+		}
+		defer fs.Close()
+		replay = fs
+	}
+
+	opts := []receiver.Option{receiver.WithReplayStore(replay), receiver.WithCloudEvents()}
+	if noTrace {
+		opts = append(opts, receiver.WithoutTracePropagation())
+	}
+	rc, err := receiver.NewWithSecret(secret, receiver.Typed(handler{}), opts...)
+	if err != nil {
+		log.Fatalf("Failed to create receiver: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/webhook", rc)
+
+	log.Printf("Listening on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
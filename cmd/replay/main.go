@@ -0,0 +1,254 @@
+// Command replay sends captured webhook deliveries again, see the capture package for the format.
+//
+// By default every delivery is signed again with the current secret and a fresh
+// webhook-timestamp. With -verbatim the original headers are sent unchanged,
+// which is useful to test that a verifier rejects stale or replayed requests.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
+	"github.com/naoyafurudono/hello-std-webhooks/ratelimit"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+const usage = `Usage: replay [options] <capture.jsonl | ->
+
+Sends the deliveries of a JSON Lines capture to a target URL and reports the responses.
+
+Options:
+`
+
+// result is the outcome of replaying one record.
+type result struct {
+	rec     *capture.Record
+	msgID   string
+	status  int
+	err     error
+	latency time.Duration
+}
+
+func main() {
+	var (
+		target   string
+		secret   string
+		verbatim bool
+		newIDs   bool
+		rate     float64
+		order    string
+		timeout  time.Duration
+		verbose  bool
+	)
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.StringVar(&target, "target", "", "URL to send to (default: WEBHOOK_TARGET_URL, or the URL of each record)")
+	flag.StringVar(&secret, "secret", "", "secret to sign with: whsec_ or whsk_ (default: WEBHOOK_SECRET)")
+	flag.BoolVar(&verbatim, "verbatim", false, "send the original headers, including the original signature and timestamp")
+	flag.BoolVar(&newIDs, "new-ids", false, "send every delivery with a new webhook-id, so receivers don't drop it as a duplicate")
+	flag.Float64Var(&rate, "rate", 0, "maximum requests per second (0: no limit)")
+	flag.StringVar(&order, "order", "recorded", "order of the deliveries: recorded, time, reverse or shuffle")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "timeout of each request")
+	flag.BoolVar(&verbose, "v", false, "print the result of every delivery")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Load env.local if it exists (ignore error if not found)
+	_ = godotenv.Load("env.local")
+	if target == "" {
+		target = os.Getenv("WEBHOOK_TARGET_URL")
+	}
+	if secret == "" {
+		secret = os.Getenv("WEBHOOK_SECRET")
+	}
+	if verbatim && newIDs {
+		log.Fatal("-new-ids changes the signed content, so it can't be used with -verbatim")
+	}
+
+	var signer signing.Signer
+	if !verbatim {
+		if secret == "" {
+			log.Fatal("WEBHOOK_SECRET is not set. Run 'make setup-env' first, or pass -secret or -verbatim.")
+		}
+		var err error
+		if signer, err = signing.NewSigner(secret); err != nil {
+			log.Fatalf("Invalid secret: %v", err)
+		}
+	}
+
+	records, err := capture.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read capture: %v", err)
+	}
+	if err := sortRecords(records, order); err != nil {
+		log.Fatal(err)
+	}
+
+	limiter := ratelimit.New(rate, 1, 0)
+	httpClient := &http.Client{Timeout: timeout}
+	ctx := context.Background()
+
+	results := make([]result, 0, len(records))
+	for _, rec := range records {
+		release, err := limiter.Acquire(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res := replay(ctx, httpClient, rec, target, signer, newIDs)
+		release()
+
+		if verbose {
+			printResult(res)
+		}
+		results = append(results, res)
+	}
+
+	if !report(os.Stdout, results) {
+		os.Exit(1)
+	}
+}
+
+// sortRecords orders the records for replay.
+func sortRecords(records []*capture.Record, order string) error {
+	switch order {
+	case "recorded":
+	case "time":
+		slices.SortStableFunc(records, func(a, b *capture.Record) int {
+			return a.Time.Compare(b.Time)
+		})
+	case "reverse":
+		slices.Reverse(records)
+	case "shuffle":
+		rand.Shuffle(len(records), func(i, j int) {
+			records[i], records[j] = records[j], records[i]
+		})
+	default:
+		return fmt.Errorf("unknown order %q", order)
+	}
+	return nil
+}
+
+// replay sends one captured delivery to target, or to its original URL if target is empty.
+// It is signed again with signer, unless signer is nil.
+func replay(ctx context.Context, httpClient *http.Client, rec *capture.Record, target string, signer signing.Signer, newID bool) result {
+	res := result{rec: rec, msgID: rec.MsgID()}
+	url := target
+	if url == "" {
+		url = rec.URL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(rec.Body)))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	for k, v := range rec.Headers {
+		req.Header[k] = slices.Clone(v)
+	}
+	req.Header.Del("Content-Length")
+
+	if signer != nil {
+		if newID {
+			res.msgID = "msg_" + uuid.New().String()
+		}
+		now := time.Now()
+		signature, err := signer.Sign(res.msgID, now, []byte(rec.Body))
+		if err != nil {
+			res.err = err
+			return res
+		}
+		req.Header.Set("webhook-id", res.msgID)
+		req.Header.Set("webhook-timestamp", strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set("webhook-signature", signature)
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	res.latency = time.Since(start)
+	res.status = resp.StatusCode
+	return res
+}
+
+func printResult(res result) {
+	if res.err != nil {
+		fmt.Printf("%s  error: %v\n", res.msgID, res.err)
+		return
+	}
+	fmt.Printf("%s  %d  %s\n", res.msgID, res.status, res.latency.Truncate(time.Microsecond))
+}
+
+// report prints a summary of the results and reports whether every delivery got a 2xx response.
+func report(w io.Writer, results []result) bool {
+	var (
+		byStatus = make(map[int]int)
+		errors   int
+		changed  int
+		ok       = true
+		total    time.Duration
+	)
+	for _, res := range results {
+		if res.err != nil {
+			errors++
+			ok = false
+			continue
+		}
+		byStatus[res.status]++
+		total += res.latency
+		if res.status < 200 || res.status >= 300 {
+			ok = false
+		}
+		if res.rec.Response != nil && res.rec.Response.Status != res.status {
+			changed++
+		}
+	}
+
+	fmt.Fprintf(w, "Replayed %d deliveries\n", len(results))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCOUNT")
+	statuses := make([]int, 0, len(byStatus))
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%d %s\t%d\n", status, http.StatusText(status), byStatus[status])
+	}
+	if errors > 0 {
+		fmt.Fprintf(tw, "error\t%d\n", errors)
+	}
+	tw.Flush()
+
+	if n := len(results) - errors; n > 0 {
+		fmt.Fprintf(w, "Average latency: %s\n", (total / time.Duration(n)).Truncate(time.Microsecond))
+	}
+	if changed > 0 {
+		fmt.Fprintf(w, "%d deliveries got a different status than when they were captured\n", changed)
+	}
+	return ok
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/capture"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+const userCreated = `{"id":"evt_1","type":"user.created","timestamp":"2025-01-01T12:00:00Z","data":{"id":"user_1","email":"user@example.com","name":"User"}}`
+
+// newSigner returns a new whsec_ secret and its signer.
+func newSigner(t *testing.T) (string, signing.Signer) {
+	t.Helper()
+	secret, err := signing.GenerateSecret(signing.DefaultSecretBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, signer
+}
+
+// capturedAt returns a record of a delivery signed by signer at sentAt.
+func capturedAt(t *testing.T, signer signing.Signer, msgID string, sentAt time.Time) *capture.Record {
+	t.Helper()
+	sig, err := signer.Sign(msgID, sentAt, []byte(userCreated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set("webhook-id", msgID)
+	h.Set("webhook-timestamp", strconv.FormatInt(sentAt.Unix(), 10))
+	h.Set("webhook-signature", sig)
+	return &capture.Record{
+		Time:     sentAt,
+		URL:      "http://127.0.0.1:1/webhook",
+		Headers:  h,
+		Body:     userCreated,
+		Response: &capture.Response{Status: http.StatusOK},
+	}
+}
+
+func TestReplay(t *testing.T) {
+	secret, signer := newSigner(t)
+	_, otherSigner := newSigner(t)
+	rc, err := receiver.NewWithSecret(secret, receiver.Typed(receiver.BaseEventHandler{}),
+		receiver.WithReplayStore(receiver.NewMemoryReplayStore(100, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	// Captured an hour ago, when the receiver's secret was the other one
+	stale := capturedAt(t, otherSigner, "msg_stale", time.Now().Add(-time.Hour))
+	fresh := capturedAt(t, signer, "msg_fresh", time.Now())
+
+	tests := []struct {
+		name   string
+		rec    *capture.Record
+		signer signing.Signer
+		newID  bool
+		want   int
+	}{
+		{name: "verbatim", rec: fresh, want: http.StatusOK},
+		{name: "verbatim duplicate", rec: fresh, want: http.StatusOK},
+		{name: "verbatim stale", rec: stale, want: http.StatusUnauthorized},
+		{name: "signed again", rec: stale, signer: signer, want: http.StatusOK},
+		{name: "signed again with a new ID", rec: stale, signer: signer, newID: true, want: http.StatusOK},
+		{name: "signed with another secret", rec: fresh, signer: otherSigner, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := replay(context.Background(), srv.Client(), tt.rec, srv.URL, tt.signer, tt.newID)
+			if res.err != nil {
+				t.Fatal(res.err)
+			}
+			if res.status != tt.want {
+				t.Errorf("status %d, want %d", res.status, tt.want)
+			}
+			if newID := res.msgID != tt.rec.MsgID(); newID != tt.newID {
+				t.Errorf("webhook-id %s for a capture of %s", res.msgID, tt.rec.MsgID())
+			}
+		})
+	}
+
+	// Without a target, the delivery goes to the URL it was captured for
+	rec := *fresh
+	rec.URL = srv.URL
+	if res := replay(context.Background(), srv.Client(), &rec, "", signer, true); res.err != nil || res.status != http.StatusOK {
+		t.Errorf("replay to the captured URL = %d %v, want 200", res.status, res.err)
+	}
+}
+
+func TestSortRecords(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	newRecords := func() []*capture.Record {
+		var records []*capture.Record
+		for i, offset := range []int{2, 0, 1} {
+			h := http.Header{}
+			h.Set("webhook-id", "msg_"+strconv.Itoa(i))
+			records = append(records, &capture.Record{Time: t0.Add(time.Duration(offset) * time.Minute), Headers: h})
+		}
+		return records
+	}
+	ids := func(records []*capture.Record) []string {
+		var ids []string
+		for _, rec := range records {
+			ids = append(ids, rec.MsgID())
+		}
+		return ids
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{order: "recorded", want: []string{"msg_0", "msg_1", "msg_2"}},
+		{order: "time", want: []string{"msg_1", "msg_2", "msg_0"}},
+		{order: "reverse", want: []string{"msg_2", "msg_1", "msg_0"}},
+	}
+	for _, tt := range tests {
+		records := newRecords()
+		if err := sortRecords(records, tt.order); err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(records); !slices.Equal(got, tt.want) {
+			t.Errorf("order %s = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+
+	records := newRecords()
+	if err := sortRecords(records, "shuffle"); err != nil {
+		t.Fatal(err)
+	}
+	got := ids(records)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"msg_0", "msg_1", "msg_2"}) {
+		t.Errorf("shuffled records = %v, want the same records", got)
+	}
+	if err := sortRecords(newRecords(), "random"); err == nil {
+		t.Error("sortRecords accepted an unknown order")
+	}
+}
+
+func TestReport(t *testing.T) {
+	captured := &capture.Record{Response: &capture.Response{Status: http.StatusOK}}
+	tests := []struct {
+		name    string
+		results []result
+		ok      bool
+		lines   []string
+	}{
+		{
+			name: "all delivered",
+			results: []result{
+				{rec: captured, status: http.StatusOK, latency: time.Millisecond},
+				{rec: captured, status: http.StatusOK, latency: 3 * time.Millisecond},
+			},
+			ok:    true,
+			lines: []string{"Replayed 2 deliveries", "200 OK", "Average latency: 2ms"},
+		},
+		{
+			name: "rejected and failed",
+			results: []result{
+				{rec: captured, status: http.StatusOK, latency: time.Millisecond},
+				{rec: captured, status: http.StatusUnauthorized, latency: time.Millisecond},
+				{rec: captured, err: errors.New("connection refused")},
+			},
+			lines: []string{
+				"Replayed 3 deliveries",
+				"401 Unauthorized",
+				"error",
+				"1 deliveries got a different status than when they were captured",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if ok := report(&b, tt.results); ok != tt.ok {
+				t.Errorf("report() = %v, want %v", ok, tt.ok)
+			}
+			for _, line := range tt.lines {
+				if !strings.Contains(b.String(), line) {
+					t.Errorf("report lacks %q:\n%s", line, b.String())
+				}
+			}
+		})
+	}
+}
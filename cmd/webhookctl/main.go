@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/outbox"
+)
+
+const usage = `Usage: webhookctl <command> [options]
+
+Commands:
+  dlq list                       List dead letters
+  dlq inspect <msg-id>           Show a dead letter with its attempt history
+  dlq redrive [-all] [msg-id...] Move dead letters back into the outbox
+  attempts [filters]             List delivery attempts (see attempts -h)
+`
+
+// errUsage is returned for unknown commands.
+var errUsage = errors.New("usage")
+
+func main() {
+	err := errUsage
+	if len(os.Args) >= 2 {
+		switch os.Args[1] {
+		case "dlq":
+			err = runDLQ(os.Args[2:])
+		case "attempts":
+			err = runAttempts(os.Args[2:])
+		}
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runDLQ(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "list", "inspect", "redrive":
+	default:
+		return errUsage
+	}
+
+	var (
+		dlqPath    string
+		outboxPath string
+		all        bool
+	)
+	fs := flag.NewFlagSet("dlq "+args[0], flag.ExitOnError)
+	fs.StringVar(&dlqPath, "dlq", "deadletter.jsonl", "dead letter file")
+	fs.StringVar(&outboxPath, "outbox", "outbox.jsonl", "outbox file (redrive only)")
+	if args[0] == "redrive" {
+		fs.BoolVar(&all, "all", false, "redrive every dead letter")
+	}
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	dlq, err := outbox.OpenFileDeadLetterStore(dlqPath)
+	if err != nil {
+		return fmt.Errorf("open dead letter store: %w", err)
+	}
+	defer dlq.Close()
+
+	switch args[0] {
+	case "list":
+		return listDeadLetters(ctx, dlq)
+	case "inspect":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("inspect takes exactly one message ID")
+		}
+		return inspectDeadLetter(ctx, dlq, fs.Arg(0))
+	default: // redrive
+		if !all && fs.NArg() == 0 {
+			return fmt.Errorf("redrive takes message IDs or -all")
+		}
+		store, err := outbox.OpenFileStore(outboxPath)
+		if err != nil {
+			return fmt.Errorf("open outbox: %w", err)
+		}
+		defer store.Close()
+
+		if all {
+			n, err := outbox.RedriveAll(ctx, dlq, store)
+			fmt.Printf("Redrove %d dead letter(s) into %s\n", n, outboxPath)
+			return err
+		}
+		if err := outbox.Redrive(ctx, dlq, store, fs.Args()...); err != nil {
+			return err
+		}
+		fmt.Printf("Redrove %d dead letter(s) into %s\n", fs.NArg(), outboxPath)
+		return nil
+	}
+}
+
+func listDeadLetters(ctx context.Context, dlq outbox.DeadLetterStore) error {
+	dls, err := dlq.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MSG ID\tTYPE\tSTATUS\tATTEMPTS\tFAILED AT")
+	for _, dl := range dls {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n",
+			dl.MsgID, dl.Event.Type, dl.LastStatusCode, len(dl.Attempts), dl.FailedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func inspectDeadLetter(ctx context.Context, dlq outbox.DeadLetterStore, msgID string) error {
+	dl, err := dlq.Get(ctx, msgID)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dl)
+}
+
+func runAttempts(args []string) error {
+	var (
+		logPath string
+		f       attemptlog.Filter
+		since   string
+		until   string
+		asJSON  bool
+	)
+	fs := flag.NewFlagSet("attempts", flag.ExitOnError)
+	fs.StringVar(&logPath, "log", "attempts.jsonl", "attempt log file")
+	fs.StringVar(&f.Endpoint, "endpoint", "", "only attempts to this endpoint ID or URL")
+	fs.StringVar(&f.EventType, "type", "", "only attempts of this event type")
+	fs.StringVar(&f.MsgID, "msg-id", "", "only attempts of this message ID")
+	fs.StringVar(&since, "since", "", `only attempts at or after this time: RFC 3339, or a duration like "24h" before now`)
+	fs.StringVar(&until, "until", "", "only attempts before this time, in the same format as -since")
+	fs.IntVar(&f.Limit, "limit", 0, "show only the newest n attempts")
+	fs.BoolVar(&asJSON, "json", false, "print full attempt records as JSON Lines")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	now := time.Now()
+	var err error
+	if since != "" {
+		if f.Since, err = attemptlog.ParseTime(since, now); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if until != "" {
+		if f.Until, err = attemptlog.ParseTime(until, now); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+
+	attempts, err := attemptlog.QueryFile(logPath, f)
+	if err != nil {
+		return fmt.Errorf("read attempt log: %w", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, a := range attempts {
+			if err := enc.Encode(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AT\tMSG ID\tENDPOINT\tTYPE\tSTATUS\tLATENCY\tERROR")
+	for _, a := range attempts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			a.At.Format(time.RFC3339), a.MsgID, a.Endpoint, a.EventType, a.StatusCode,
+			a.Latency.Truncate(time.Microsecond), a.ErrorClass)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,305 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+)
+
+// Default batch window.
+const (
+	defaultBatchSize = 100
+	defaultBatchWait = time.Second
+)
+
+// maxBatchSize is the most events a batch may hold, the maxItems of WebhookEventBatch in openapi.yaml.
+const maxBatchSize = 1000
+
+// WithBatching enables batch delivery for endpoints with Batch set.
+// Their events are accumulated per endpoint until maxEvents are pending or maxWait
+// has passed since the first one, and then sent as one signed JSON array.
+// maxEvents is at most 1000, the most events receivers accept in one batch.
+// onResult is called with the outcome of every batch; it may be nil.
+// A batch is sent with the trace context of the Dispatch call that started it,
+// but is not canceled with that call.
+// Call Flush before shutting down, so that pending events are sent.
+func WithBatching(maxEvents int, maxWait time.Duration, onResult func(*BatchDelivery)) Option {
+	return func(d *Dispatcher) {
+		if maxEvents < 1 {
+			maxEvents = defaultBatchSize
+		}
+		maxEvents = min(maxEvents, maxBatchSize)
+		if maxWait <= 0 {
+			maxWait = defaultBatchWait
+		}
+		d.batches = &batcher{
+			d:         d,
+			maxEvents: maxEvents,
+			maxWait:   maxWait,
+			onResult:  onResult,
+			pending:   make(map[string]*pendingBatch),
+			sending:   make(map[*pendingBatch]*sendingBatch),
+		}
+	}
+}
+
+// BatchResult is the outcome of one event of a batch.
+type BatchResult struct {
+	EventID string
+	OK      bool
+	// Error is why the event failed: the receiver's reason, or why the batch failed.
+	Error string
+}
+
+// BatchDelivery is the record of sending a batch of events to one endpoint.
+type BatchDelivery struct {
+	// ID identifies this delivery.
+	ID string
+	// MsgID is the webhook-id of the batch, derived from the webhook-ids of its events.
+	MsgID      string
+	EndpointID string
+	Events     []*api.WebhookEvent
+	// Response is the decoded response, if the endpoint answered with a known status.
+	Response api.UserEventBatchRes
+	// Err is set if the whole batch failed.
+	Err error
+	// Results has the outcome of each event, in the order of Events.
+	Results    []BatchResult
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Failed returns the events which the endpoint did not handle, so they can be sent again.
+func (b *BatchDelivery) Failed() []*api.WebhookEvent {
+	var failed []*api.WebhookEvent
+	for i, r := range b.Results {
+		if !r.OK {
+			failed = append(failed, b.Events[i])
+		}
+	}
+	return failed
+}
+
+// Flush sends every pending batch now and waits until all batches being sent are done.
+// If ctx is done first, the batches still being sent are canceled and ctx's error is returned.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	if d.batches == nil {
+		return nil
+	}
+	return d.batches.flush(ctx)
+}
+
+// batcher accumulates the events of batching endpoints.
+type batcher struct {
+	d         *Dispatcher
+	maxEvents int
+	maxWait   time.Duration
+	onResult  func(*BatchDelivery)
+
+	mu      sync.Mutex
+	pending map[string]*pendingBatch // by endpoint ID
+	sending map[*pendingBatch]*sendingBatch
+}
+
+type pendingBatch struct {
+	// ctx is the context of the first event without its cancellation, for tracing
+	ctx        context.Context
+	endpointID string
+	events     []*api.WebhookEvent
+	msgIDs     []string
+	timer      *time.Timer
+}
+
+// sendingBatch is a batch being sent.
+type sendingBatch struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed when sent
+}
+
+// add queues the event for the endpoint, sending the batch once it is full.
+func (b *batcher) add(ctx context.Context, endpointID string, event *api.WebhookEvent, msgID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pb, ok := b.pending[endpointID]
+	if !ok {
+		pb = &pendingBatch{ctx: context.WithoutCancel(ctx), endpointID: endpointID}
+		b.pending[endpointID] = pb
+		pb.timer = time.AfterFunc(b.maxWait, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.startLocked(pb)
+		})
+	}
+	pb.events = append(pb.events, event)
+	pb.msgIDs = append(pb.msgIDs, msgID)
+	if len(pb.events) >= b.maxEvents {
+		b.startLocked(pb)
+	}
+}
+
+// startLocked starts sending pb unless it has already been started. b.mu must be held.
+func (b *batcher) startLocked(pb *pendingBatch) {
+	if b.pending[pb.endpointID] != pb {
+		return
+	}
+	delete(b.pending, pb.endpointID)
+	pb.timer.Stop()
+
+	ctx, cancel := context.WithCancel(pb.ctx)
+	sb := &sendingBatch{cancel: cancel, done: make(chan struct{})}
+	b.sending[pb] = sb
+	go func() {
+		defer func() {
+			b.mu.Lock()
+			delete(b.sending, pb)
+			b.mu.Unlock()
+			cancel()
+			close(sb.done)
+		}()
+		b.send(ctx, pb)
+	}()
+}
+
+func (b *batcher) flush(ctx context.Context) error {
+	b.mu.Lock()
+	for _, pb := range b.pending {
+		b.startLocked(pb)
+	}
+	sending := make([]*sendingBatch, 0, len(b.sending))
+	for _, sb := range b.sending {
+		sending = append(sending, sb)
+	}
+	b.mu.Unlock()
+
+	for i, sb := range sending {
+		select {
+		case <-sb.done:
+		case <-ctx.Done():
+			for _, sb := range sending[i:] {
+				sb.cancel()
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// send delivers a batch to its endpoint and reports the outcome.
+func (b *batcher) send(ctx context.Context, pb *pendingBatch) {
+	bd := &BatchDelivery{
+		ID:         "bat_" + uuid.New().String(),
+		MsgID:      batchMessageID(pb.msgIDs),
+		EndpointID: pb.endpointID,
+		Events:     pb.events,
+		Results:    make([]BatchResult, len(pb.events)),
+		StartedAt:  time.Now(),
+	}
+	for i, event := range pb.events {
+		bd.Results[i] = BatchResult{EventID: client.EventID(event)}
+	}
+
+	b.deliver(ctx, bd)
+
+	bd.FinishedAt = time.Now()
+	if b.onResult != nil {
+		b.onResult(bd)
+	}
+}
+
+// deliver sends the events of bd which can be encoded for the endpoint and fills in the results.
+func (b *batcher) deliver(ctx context.Context, bd *BatchDelivery) {
+	ep, err := b.d.registry.Get(bd.EndpointID)
+	if err != nil {
+		bd.Err = err
+		failAll(bd, err.Error())
+		return
+	}
+	wc := b.d.clientFor(ep)
+
+	// Events which can't be converted to the pinned version fail on their own
+	var (
+		bodies [][]byte
+		sent   []int // indices into bd.Events of bodies
+	)
+	for i, event := range bd.Events {
+		body, err := b.d.encode(wc, ep, event)
+		if err != nil {
+			bd.Results[i].Error = err.Error()
+			continue
+		}
+		bodies = append(bodies, body)
+		sent = append(sent, i)
+	}
+	if len(bodies) == 0 {
+		bd.Err = errors.New("dispatch: no event of the batch could be encoded")
+		return
+	}
+
+	bd.Response, bd.Err = wc.SendBatchRaw(ctx, bd.MsgID, bodies)
+	if bd.Err != nil {
+		failAll(bd, bd.Err.Error())
+		return
+	}
+
+	switch res := bd.Response.(type) {
+	case *api.BatchResponse:
+		matchResults(bd, sent, res.Results)
+	case *api.UserEventBatchBadRequest:
+		failAll(bd, res.Error)
+	case *api.UserEventBatchUnauthorized:
+		failAll(bd, res.Error)
+	}
+}
+
+// matchResults fills in the results of the sent events of bd from the receiver's results.
+// Results are matched by event ID, or by position if the receiver left the ID out;
+// a result for another event counts as a failure.
+func matchResults(bd *BatchDelivery, sent []int, results []api.BatchEventResult) {
+	byID := make(map[string]*api.BatchEventResult, len(results))
+	for k := range results {
+		if id := results[k].ID; id != "" {
+			byID[id] = &results[k]
+		}
+	}
+
+	for j, i := range sent {
+		r, ok := byID[bd.Results[i].EventID]
+		if !ok {
+			if j >= len(results) {
+				bd.Results[i].Error = "no result for event"
+				continue
+			}
+			r = &results[j]
+			if r.ID != "" {
+				bd.Results[i].Error = fmt.Sprintf("no result for event, result %d is for %s", j, r.ID)
+				continue
+			}
+		}
+		bd.Results[i].OK = r.Success
+		bd.Results[i].Error = r.Error.Or("")
+	}
+}
+
+// failAll marks every event of bd which has not failed yet as failed with reason.
+func failAll(bd *BatchDelivery, reason string) {
+	for i := range bd.Results {
+		if bd.Results[i].Error == "" {
+			bd.Results[i].Error = reason
+		}
+	}
+}
+
+// batchMessageID derives the webhook-id of a batch from the webhook-ids of its events,
+// so that sending the same events again yields the same webhook-id.
+func batchMessageID(msgIDs []string) string {
+	return "msg_" + uuid.NewSHA1(client.MessageIDNamespace, []byte(strings.Join(msgIDs, "\x00"))).String()
+}
@@ -0,0 +1,128 @@
+package dispatch
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+func TestMatchResults(t *testing.T) {
+	ok := func(id string) api.BatchEventResult { return api.BatchEventResult{ID: id, Success: true} }
+	failed := func(id string) api.BatchEventResult {
+		return api.BatchEventResult{ID: id, Error: api.NewOptString("failed")}
+	}
+	tests := []struct {
+		name    string
+		events  []string
+		sent    []int
+		results []api.BatchEventResult
+		want    []bool
+	}{
+		{name: "in order", events: []string{"evt_1", "evt_2"}, sent: []int{0, 1}, results: []api.BatchEventResult{ok("evt_1"), failed("evt_2")}, want: []bool{true, false}},
+		{name: "reordered", events: []string{"evt_1", "evt_2"}, sent: []int{0, 1}, results: []api.BatchEventResult{failed("evt_2"), ok("evt_1")}, want: []bool{true, false}},
+		{name: "without IDs", events: []string{"evt_1", "evt_2"}, sent: []int{0, 1}, results: []api.BatchEventResult{ok(""), failed("")}, want: []bool{true, false}},
+		{name: "missing result", events: []string{"evt_1", "evt_2"}, sent: []int{0, 1}, results: []api.BatchEventResult{ok("evt_1")}, want: []bool{true, false}},
+		{name: "result for another event", events: []string{"evt_1", "evt_2"}, sent: []int{0, 1}, results: []api.BatchEventResult{ok("evt_1"), ok("evt_9")}, want: []bool{true, false}},
+		{name: "unsent event", events: []string{"evt_1", "evt_2"}, sent: []int{1}, results: []api.BatchEventResult{ok("")}, want: []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bd := &BatchDelivery{Results: make([]BatchResult, len(tt.events))}
+			for i, id := range tt.events {
+				bd.Results[i].EventID = id
+			}
+			matchResults(bd, tt.sent, tt.results)
+			for i, want := range tt.want {
+				if r := bd.Results[i]; r.OK != want {
+					t.Errorf("event %s: OK = %v, want %v (error %q)", r.EventID, r.OK, want, r.Error)
+				}
+			}
+		})
+	}
+}
+
+// localDialer returns a safe dialer allowing the loopback address of test servers.
+func localDialer(t *testing.T) *netguard.Dialer {
+	t.Helper()
+	dl, err := netguard.New("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dl
+}
+
+// newTestEndpoint starts a receiver calling h and returns an endpoint delivering to it.
+func newTestEndpoint(t *testing.T, id string, h receiver.EventHandler, opts ...receiver.Option) Endpoint {
+	t.Helper()
+	secret, err := signing.GenerateSecret(signing.DefaultSecretBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := receiver.NewWithSecret(secret, receiver.Typed(h), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	return Endpoint{ID: id, URL: srv.URL, Secret: secret}
+}
+
+func TestDispatcherBatches(t *testing.T) {
+	ep := newTestEndpoint(t, "ep_batch", receiver.BaseEventHandler{})
+	ep.Batch = true
+	registry := NewRegistry(WithAllowHTTP())
+	if err := registry.Add(ep); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		results []*BatchDelivery
+	)
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)), WithBatching(10, time.Hour, func(bd *BatchDelivery) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, bd)
+	}))
+
+	ctx := context.Background()
+	events := []*api.WebhookEvent{
+		client.NewUserCreatedEvent(api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"}),
+		client.NewUserDeletedEvent(api.UserDeletedData{ID: "user_1"}),
+	}
+	for _, event := range events {
+		deliveries, err := d.Send(ctx, event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 0 {
+			t.Errorf("%d single deliveries to a batching endpoint", len(deliveries))
+		}
+	}
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(results) != 1 {
+		t.Fatalf("%d batches, want 1", len(results))
+	}
+	bd := results[0]
+	if bd.Err != nil {
+		t.Fatal(bd.Err)
+	}
+	for i, r := range bd.Results {
+		if !r.OK || r.EventID != client.EventID(events[i]) {
+			t.Errorf("result %d = %+v, want %s handled", i, r, client.EventID(events[i]))
+		}
+	}
+}
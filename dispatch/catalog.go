@@ -0,0 +1,63 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+)
+
+// Catalog is the set of event types which endpoints can subscribe to.
+type Catalog struct {
+	types []string
+}
+
+// NewCatalog creates a Catalog of the given event types.
+func NewCatalog(types ...string) *Catalog {
+	c := &Catalog{types: slices.Clone(types)}
+	slices.Sort(c.types)
+	c.types = slices.Compact(c.types)
+	return c
+}
+
+// Has reports whether the event type is in the catalog.
+func (c *Catalog) Has(eventType string) bool {
+	_, ok := slices.BinarySearch(c.types, eventType)
+	return ok
+}
+
+// Types returns the event types in the catalog, sorted.
+func (c *Catalog) Types() []string {
+	return slices.Clone(c.types)
+}
+
+// Validate checks that every subscription refers to cataloged event types.
+// An exact name must be in the catalog and a pattern must match at least one type.
+func (c *Catalog) Validate(subscriptions []string) error {
+	for _, sub := range subscriptions {
+		if err := validatePattern(sub); err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(c.types, func(t string) bool { return matchEventType(sub, t) }) {
+			return fmt.Errorf("subscription %q matches no event type in the catalog", sub)
+		}
+	}
+	return nil
+}
+
+// matchEventType matches an event type against an exact name or a glob pattern
+// such as "user.*", using path.Match syntax.
+func matchEventType(pattern, eventType string) bool {
+	ok, err := path.Match(pattern, eventType)
+	return err == nil && ok
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty subscription")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("subscription %q: %w", pattern, err)
+	}
+	return nil
+}
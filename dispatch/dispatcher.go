@@ -0,0 +1,305 @@
+package dispatch
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/attemptlog"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+	"github.com/naoyafurudono/hello-std-webhooks/versioning"
+)
+
+// Default number of endpoints delivered to at the same time.
+const defaultConcurrency = 8
+
+// Option is a functional option for configuring Dispatcher.
+type Option func(*Dispatcher)
+
+// WithConcurrency limits how many endpoints are delivered to at the same time.
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+// WithClientOptions sets options for the WebhookClient used for each endpoint,
+// such as the HTTP client or the retry policy.
+func WithClientOptions(opts ...client.Option) Option {
+	return func(d *Dispatcher) {
+		d.clientOpts = append(d.clientOpts, opts...)
+	}
+}
+
+// WithSafeDialer makes every connection to an endpoint through dl,
+// for example to allow some internal hosts. See the netguard package.
+func WithSafeDialer(dl *netguard.Dialer) Option {
+	return func(d *Dispatcher) {
+		d.dialer = dl
+	}
+}
+
+// WithoutSafeDialer lets the Dispatcher connect to any address, including loopback,
+// private and metadata addresses. Only use it if every endpoint URL is trusted.
+func WithoutSafeDialer() Option {
+	return func(d *Dispatcher) {
+		d.dialer = nil
+	}
+}
+
+// WithValidator validates each event once before it is fanned out.
+// An invalid event is sent to no endpoint and Dispatch returns the validator's error.
+func WithValidator(v client.Validator) Option {
+	return func(d *Dispatcher) {
+		d.validator = v
+	}
+}
+
+// WithVersions converts events to the schema version each endpoint is pinned to
+// with the transforms in vr. Without it, Endpoint.Versions is ignored.
+func WithVersions(vr *versioning.Registry) Option {
+	return func(d *Dispatcher) {
+		d.versions = vr
+	}
+}
+
+// WithMessageIDFunc sets how Send derives the webhook-id for each endpoint,
+// with the endpoint ID as the endpoint.
+// The default only depends on the event ID, see client.MessageID.
+func WithMessageIDFunc(fn client.MessageIDFunc) Option {
+	return func(d *Dispatcher) {
+		d.messageID = fn
+	}
+}
+
+// WithAttemptLog records every attempt to every endpoint in store, under the endpoint ID.
+// Add client.WithAttemptBodies with WithClientOptions to record request bodies as well.
+func WithAttemptLog(store attemptlog.Store) Option {
+	return func(d *Dispatcher) {
+		d.attemptLog = store
+	}
+}
+
+// Delivery is the record of sending one event to one endpoint.
+type Delivery struct {
+	// ID identifies this delivery.
+	ID string
+	// MsgID is the webhook-id. Unless it is derived per endpoint, see WithMessageIDFunc,
+	// it is shared by all deliveries of the same event.
+	MsgID      string
+	EndpointID string
+	// Response is the decoded response, if the endpoint answered with a known status.
+	Response api.UserEventRes
+	// Err is set if the delivery failed.
+	Err        error
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// OK reports whether the endpoint accepted the event.
+func (d *Delivery) OK() bool {
+	_, ok := d.Response.(*api.WebhookResponse)
+	return d.Err == nil && ok
+}
+
+// Dispatcher fans events out to every endpoint in a Registry.
+type Dispatcher struct {
+	registry    *Registry
+	concurrency int
+	clientOpts  []client.Option
+	validator   client.Validator
+	versions    *versioning.Registry
+	messageID   client.MessageIDFunc
+	batches     *batcher
+	attemptLog  attemptlog.Store
+	dialer      *netguard.Dialer
+
+	mu      sync.Mutex
+	clients map[string]*endpointClient // by endpoint ID
+}
+
+// endpointClient is the cached client of an endpoint, with what it was created from.
+type endpointClient struct {
+	keys   *signing.KeyRing
+	secret string
+	wc     *client.WebhookClient
+}
+
+// NewDispatcher creates a Dispatcher sending to the endpoints in registry.
+// Endpoint URLs come from customers, so connections to non-public addresses are refused
+// unless another dialer is set with WithSafeDialer or WithoutSafeDialer.
+func NewDispatcher(registry *Registry, opts ...Option) *Dispatcher {
+	// Without an allowlist, netguard.New can't fail
+	dialer, _ := netguard.New()
+	d := &Dispatcher{
+		dialer:      dialer,
+		registry:    registry,
+		concurrency: defaultConcurrency,
+		clients:     make(map[string]*endpointClient),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+	registry.onRemove(d.forget)
+
+	return d
+}
+
+// Dispatch sends the event to every endpoint subscribed to its type concurrently,
+// converting it to the endpoint's pinned schema version and signing each request
+// with the endpoint's own active keys.
+// All deliveries share msgID as webhook-id.
+// The returned deliveries are in the same order as Registry.List.
+// Endpoints receiving batches are left out, see WithBatching, and so are pending endpoints.
+// Deliveries over an endpoint's rate or in-flight limits wait in line rather than being dropped.
+// An error is returned only if the event fails validation; delivery failures are recorded in each Delivery.
+func (d *Dispatcher) Dispatch(ctx context.Context, msgID string, event *api.WebhookEvent) ([]*Delivery, error) {
+	return d.dispatch(ctx, event, func(*api.WebhookEvent, string) string { return msgID })
+}
+
+// Send is like Dispatch, but derives the webhook-id of each delivery from the event
+// and the endpoint ID, see WithMessageIDFunc. Sending the same event again
+// therefore yields the same webhook-ids, so receivers can drop the duplicates.
+func (d *Dispatcher) Send(ctx context.Context, event *api.WebhookEvent) ([]*Delivery, error) {
+	msgID := d.messageID
+	if msgID == nil {
+		msgID = func(event *api.WebhookEvent, _ string) string { return client.MessageID(event) }
+	}
+	return d.dispatch(ctx, event, msgID)
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event *api.WebhookEvent, msgID client.MessageIDFunc) ([]*Delivery, error) {
+	// Give the event an ID shared by all endpoints
+	event = client.Stamp(event, "")
+
+	if d.validator != nil {
+		if err := d.validator.Validate(event); err != nil {
+			return nil, err
+		}
+	}
+
+	// Filter endpoints before anything is signed
+	var endpoints []Endpoint
+	for _, ep := range d.registry.List() {
+		if ep.Pending() || !ep.Subscribed(string(event.Type)) {
+			continue
+		}
+		if ep.Batch && d.batches != nil {
+			d.batches.add(ctx, ep.ID, event, msgID(event, ep.ID))
+			continue
+		}
+		endpoints = append(endpoints, ep)
+	}
+	deliveries := make([]*Delivery, len(endpoints))
+
+	concurrency := d.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		deliveries[i] = &Delivery{
+			ID:         "dlv_" + uuid.New().String(),
+			MsgID:      msgID(event, ep.ID),
+			EndpointID: ep.ID,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				deliveries[i].Err = ctx.Err()
+				return
+			}
+
+			d.deliver(ctx, ep, event, deliveries[i])
+		}()
+	}
+	wg.Wait()
+
+	return deliveries, nil
+}
+
+// deliver sends the event to a single endpoint and fills in the delivery record.
+func (d *Dispatcher) deliver(ctx context.Context, ep Endpoint, event *api.WebhookEvent, dlv *Delivery) {
+	dlv.StartedAt = time.Now()
+	defer func() {
+		dlv.FinishedAt = time.Now()
+	}()
+
+	wc := d.clientFor(ep)
+	body, err := d.encode(wc, ep, event)
+	if err != nil {
+		dlv.Err = err
+		return
+	}
+	dlv.Response, dlv.Err = wc.SendRaw(ctx, dlv.MsgID, body)
+}
+
+// clientFor returns the client delivering to an endpoint, creating it on first use and again
+// once the endpoint was added anew or its secret was rotated.
+func (d *Dispatcher) clientFor(ep Endpoint) *client.WebhookClient {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if c, ok := d.clients[ep.ID]; ok && c.keys == ep.keys && c.secret == ep.Secret {
+		return c.wc
+	}
+	wc := d.newClient(ep)
+	d.clients[ep.ID] = &endpointClient{keys: ep.keys, secret: ep.Secret, wc: wc}
+	return wc
+}
+
+// forget drops the cached client of a removed endpoint.
+func (d *Dispatcher) forget(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.clients, id)
+}
+
+// newClient creates the client delivering to an endpoint, signing with the endpoint's keys,
+// waiting for its limits, stopping while its circuit is open and refusing non-public addresses.
+func (d *Dispatcher) newClient(ep Endpoint) *client.WebhookClient {
+	opts := d.clientOpts
+	if d.dialer != nil {
+		// First, so that a dialer passed with WithClientOptions takes precedence
+		opts = append([]client.Option{client.WithSafeDialer(d.dialer)}, opts...)
+	}
+	if ep.limiter != nil {
+		opts = append(slices.Clip(opts), client.WithLimiter(ep.limiter))
+	}
+	if ep.breaker != nil {
+		opts = append(slices.Clip(opts), client.WithBreaker(ep.breaker))
+	}
+	if d.attemptLog != nil {
+		opts = append(slices.Clip(opts), client.WithAttemptLog(d.attemptLog, ep.ID))
+	}
+	if ep.NoTracePropagation {
+		opts = append(slices.Clip(opts), client.WithoutTracePropagation())
+	}
+	return client.NewWebhookClientWithSigner(ep.URL, ep.keys, opts...)
+}
+
+// encode encodes the event for the endpoint, converted to the version the endpoint is pinned to.
+func (d *Dispatcher) encode(wc *client.WebhookClient, ep Endpoint, event *api.WebhookEvent) ([]byte, error) {
+	body, err := wc.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+	if version, ok := ep.Versions[string(event.Type)]; ok && d.versions != nil {
+		return d.versions.ConvertEvent(body, version)
+	}
+	return body, nil
+}
@@ -0,0 +1,345 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+	"github.com/naoyafurudono/hello-std-webhooks/netguard"
+	"github.com/naoyafurudono/hello-std-webhooks/receiver"
+	"github.com/naoyafurudono/hello-std-webhooks/schema"
+)
+
+func testEvent() *api.WebhookEvent {
+	return client.NewUserCreatedEvent(api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"})
+}
+
+func TestDispatcherSafeDialer(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantBlocked bool
+	}{
+		{name: "default", wantBlocked: true},
+		{name: "allowlisted", opts: []Option{WithSafeDialer(localDialer(t))}},
+		{name: "without safe dialer", opts: []Option{WithoutSafeDialer()}},
+		{name: "client option", opts: []Option{WithClientOptions(client.WithSafeDialer(localDialer(t)))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(WithAllowHTTP())
+			if err := registry.Add(newTestEndpoint(t, "ep_1", receiver.BaseEventHandler{})); err != nil {
+				t.Fatal(err)
+			}
+			d := NewDispatcher(registry, tt.opts...)
+
+			deliveries, err := d.Send(context.Background(), testEvent())
+			if err != nil {
+				t.Fatal(err)
+			}
+			dlv := deliveries[0]
+			var be *netguard.BlockedError
+			if blocked := errors.As(dlv.Err, &be); blocked != tt.wantBlocked {
+				t.Errorf("delivery error = %v, want blocked: %v", dlv.Err, tt.wantBlocked)
+			}
+			if !tt.wantBlocked && !dlv.OK() {
+				t.Errorf("delivery failed: %v", dlv.Err)
+			}
+		})
+	}
+}
+
+// countingHandler counts the user.created events it handles.
+type countingHandler struct {
+	receiver.BaseEventHandler
+	calls atomic.Int32
+}
+
+func (h *countingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.calls.Add(1)
+	return nil
+}
+
+func TestDispatcherValidator(t *testing.T) {
+	v, err := schema.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &countingHandler{}
+	registry := NewRegistry(WithAllowHTTP())
+	if err := registry.Add(newTestEndpoint(t, "ep_1", h)); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)), WithValidator(v))
+	ctx := context.Background()
+
+	for _, data := range []api.UserCreatedData{
+		{ID: "user_1", Email: "not an email", Name: "User"},
+		{ID: "", Email: "user@example.com", Name: "User"},
+	} {
+		deliveries, err := d.Send(ctx, client.NewUserCreatedEvent(data))
+		var verr *schema.ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("Send(%+v) = %v, want a *schema.ValidationError", data, err)
+		}
+		if len(deliveries) != 0 {
+			t.Errorf("Send(%+v) made %d deliveries", data, len(deliveries))
+		}
+	}
+	if n := h.calls.Load(); n != 0 {
+		t.Fatalf("invalid events delivered %d times", n)
+	}
+
+	deliveries, err := d.Send(ctx, testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || !deliveries[0].OK() {
+		t.Errorf("deliveries = %+v, want one successful delivery", deliveries)
+	}
+	if n := h.calls.Load(); n != 1 {
+		t.Errorf("valid event delivered %d times, want 1", n)
+	}
+}
+
+func TestDispatcherFanOut(t *testing.T) {
+	handlers := map[string]*countingHandler{}
+	registry := NewRegistry(WithAllowHTTP())
+	for id, subs := range map[string][]string{
+		"ep_a": nil,
+		"ep_b": {"user.*"},
+		"ep_c": {"user.deleted"},
+	} {
+		h := &countingHandler{}
+		handlers[id] = h
+		ep := newTestEndpoint(t, id, h)
+		ep.Subscriptions = subs
+		if err := registry.Add(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)))
+
+	deliveries, err := d.Dispatch(context.Background(), "msg_1", testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// In the order of Registry.List, without the endpoint not subscribed
+	want := []string{"ep_a", "ep_b"}
+	if len(deliveries) != len(want) {
+		t.Fatalf("%d deliveries, want %d", len(deliveries), len(want))
+	}
+	for i, dlv := range deliveries {
+		if dlv.EndpointID != want[i] || dlv.MsgID != "msg_1" || !dlv.OK() {
+			t.Errorf("delivery %d = %s %s ok=%v (%v), want %s msg_1 ok", i, dlv.EndpointID, dlv.MsgID, dlv.OK(), dlv.Err, want[i])
+		}
+	}
+	for id, want := range map[string]int32{"ep_a": 1, "ep_b": 1, "ep_c": 0} {
+		if n := handlers[id].calls.Load(); n != want {
+			t.Errorf("%s handled %d events, want %d", id, n, want)
+		}
+	}
+}
+
+// gauge tracks how many requests are handled at a time.
+type gauge struct {
+	cur, max atomic.Int32
+}
+
+// wrap returns a handler calling h while counting the requests in flight,
+// holding each request for a while so that they overlap.
+func (g *gauge) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := g.cur.Add(1)
+		defer g.cur.Add(-1)
+		for {
+			m := g.max.Load()
+			if n <= m || g.max.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// newGaugedEndpoint is like newTestEndpoint, counting the requests in flight with g.
+func newGaugedEndpoint(t *testing.T, id string, g *gauge) Endpoint {
+	t.Helper()
+	ep := newTestEndpoint(t, id, receiver.BaseEventHandler{})
+	srv := httptest.NewServer(g.wrap(httputil.NewSingleHostReverseProxy(mustParseURL(t, ep.URL))))
+	t.Cleanup(srv.Close)
+	ep.URL = srv.URL
+	return ep
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestDispatcherConcurrency(t *testing.T) {
+	g := &gauge{}
+	registry := NewRegistry(WithAllowHTTP())
+	for i := range 4 {
+		if err := registry.Add(newGaugedEndpoint(t, fmt.Sprintf("ep_%d", i), g)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)), WithConcurrency(2))
+
+	deliveries, err := d.Send(context.Background(), testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dlv := range deliveries {
+		if !dlv.OK() {
+			t.Errorf("delivery to %s failed: %v", dlv.EndpointID, dlv.Err)
+		}
+	}
+	if n := g.max.Load(); n != 2 {
+		t.Errorf("%d endpoints delivered to at a time, want 2", n)
+	}
+}
+
+func TestDispatcherMaxInFlight(t *testing.T) {
+	g := &gauge{}
+	ep := newGaugedEndpoint(t, "ep_1", g)
+	ep.MaxInFlight = 1
+	registry := NewRegistry(WithAllowHTTP())
+	if err := registry.Add(ep); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)))
+
+	// Events dispatched at the same time wait in line for the endpoint
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliveries, err := d.Send(context.Background(), testEvent())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !deliveries[0].OK() {
+				t.Errorf("delivery failed: %v", deliveries[0].Err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := g.max.Load(); n != 1 {
+		t.Errorf("%d requests to the endpoint at a time, want 1", n)
+	}
+}
+
+func TestDispatcherCanceled(t *testing.T) {
+	// Endpoints which never answer
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	})
+	registry := NewRegistry(WithAllowHTTP())
+	for _, id := range []string{"ep_1", "ep_2"} {
+		srv := httptest.NewServer(blocking)
+		t.Cleanup(srv.Close)
+		ep := newTestEndpoint(t, id, receiver.BaseEventHandler{})
+		ep.URL = srv.URL
+		if err := registry.Add(ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)), WithConcurrency(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	deliveries, err := d.Send(ctx, testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One delivery times out in flight, and the other stops waiting for its turn
+	var waited int
+	for _, dlv := range deliveries {
+		if !errors.Is(dlv.Err, context.DeadlineExceeded) {
+			t.Errorf("delivery to %s: error %v, want %v", dlv.EndpointID, dlv.Err, context.DeadlineExceeded)
+		}
+		if dlv.StartedAt.IsZero() {
+			waited++
+		}
+	}
+	if waited != 1 {
+		t.Errorf("%d deliveries never started, want 1", waited)
+	}
+}
+
+func TestDispatcherClientCache(t *testing.T) {
+	registry := NewRegistry(WithAllowHTTP())
+	if err := registry.Add(newTestEndpoint(t, "ep_1", receiver.BaseEventHandler{})); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(registry, WithSafeDialer(localDialer(t)))
+	ctx := context.Background()
+
+	// send sends an event and returns the client which delivered it
+	send := func() *client.WebhookClient {
+		t.Helper()
+		deliveries, err := d.Send(ctx, testEvent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !deliveries[0].OK() {
+			t.Fatalf("delivery failed: %v", deliveries[0].Err)
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.clients["ep_1"].wc
+	}
+
+	first := send()
+	if send() != first {
+		t.Error("a new client was created for the same endpoint")
+	}
+
+	// Deliveries are signed with both secrets during the overlap, so the receiver still accepts them
+	if _, err := registry.RotateSecret("ep_1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	rotated := send()
+	if rotated == first {
+		t.Error("the client was reused after the secret was rotated")
+	}
+
+	if err := registry.Remove("ep_1"); err != nil {
+		t.Fatal(err)
+	}
+	d.mu.Lock()
+	_, cached := d.clients["ep_1"]
+	d.mu.Unlock()
+	if cached {
+		t.Error("the client of a removed endpoint is still cached")
+	}
+
+	if err := registry.Add(newTestEndpoint(t, "ep_1", receiver.BaseEventHandler{})); err != nil {
+		t.Fatal(err)
+	}
+	if readded := send(); readded == rotated {
+		t.Error("the client of a removed endpoint was reused for the endpoint added anew")
+	}
+}
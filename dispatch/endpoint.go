@@ -0,0 +1,380 @@
+package dispatch
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/circuit"
+	"github.com/naoyafurudono/hello-std-webhooks/ratelimit"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+var (
+	// ErrEndpointNotFound is returned when an endpoint is not registered.
+	ErrEndpointNotFound = errors.New("dispatch: endpoint not found")
+	// ErrEndpointExists is returned when registering an endpoint ID twice.
+	ErrEndpointExists = errors.New("dispatch: endpoint already exists")
+	// ErrInvalidURL is returned when registering an endpoint with an unacceptable URL.
+	ErrInvalidURL = errors.New("dispatch: invalid endpoint URL")
+)
+
+// MaxURLLength is the maximum length of an endpoint URL.
+const MaxURLLength = 2048
+
+// Endpoint is a customer URL receiving webhooks, signed with its own secret.
+type Endpoint struct {
+	ID string
+	// URL is where webhooks for this endpoint are sent.
+	URL string
+	// Secret is the signing secret: a whsec_ secret for v1 signatures
+	// or a whsk_ private key for v1a signatures. After RotateSecret it is the newest secret.
+	Secret string
+	// Subscriptions lists the event types sent to this endpoint, either exact names
+	// like "user.created" or patterns like "user.*". Empty means all event types.
+	Subscriptions []string
+	// Versions pins event types to a schema version, such as {"user.created": 1}.
+	// Events of other versions are converted by the Dispatcher before they are signed.
+	// Event types which aren't pinned are sent as they are.
+	Versions map[string]int
+	// Batch opts the endpoint into batch delivery: if the Dispatcher batches, see WithBatching,
+	// its events are accumulated and sent several at a time in one userEventBatch request.
+	Batch bool
+	// RateLimit is the maximum number of requests per second sent to the endpoint,
+	// with bursts of up to Burst requests. Zero means no limit.
+	RateLimit float64
+	Burst     int
+	// MaxInFlight is the maximum number of requests sent to the endpoint at a time.
+	// Zero means no limit.
+	MaxInFlight int
+	// NoTracePropagation stops sending the trace context and baggage of the dispatching
+	// context to the endpoint, for untrusted third-party endpoints.
+	NoTracePropagation bool
+	// VerifiedAt is when the endpoint echoed the challenge of an endpoint.verification event,
+	// see Dispatcher.Verify. Endpoints added with it set are taken as verified already.
+	VerifiedAt time.Time
+
+	// keys signs deliveries; it is created from Secret when the endpoint is added.
+	keys *signing.KeyRing
+	// limiter enforces the limits above; it is nil for an endpoint without limits.
+	limiter *ratelimit.Limiter
+	// breaker stops deliveries while the endpoint is failing; it is nil
+	// unless the Registry was created WithCircuitBreaker.
+	breaker *circuit.Breaker
+	// pending is set for an endpoint which needs verification before it receives events.
+	pending bool
+}
+
+// Pending reports whether the endpoint receives no events until it has been verified,
+// see WithVerification.
+func (ep *Endpoint) Pending() bool {
+	return ep.pending
+}
+
+// Status returns the state of the endpoint's circuit breaker, including why and when
+// the endpoint was disabled. Endpoints without a breaker are always closed.
+func (ep *Endpoint) Status() circuit.Status {
+	if ep.breaker == nil {
+		return circuit.Status{State: circuit.Closed}
+	}
+	return ep.breaker.Status()
+}
+
+// Disabled reports whether deliveries to the endpoint are stopped until it is enabled again.
+func (ep *Endpoint) Disabled() bool {
+	return ep.Status().State == circuit.Disabled
+}
+
+// Rate returns the current rate limit of the endpoint in requests per second.
+// It is lower than RateLimit while the endpoint is throttling us with 429 responses,
+// and +Inf for an endpoint without limits.
+func (ep *Endpoint) Rate() float64 {
+	if ep.limiter == nil {
+		return math.Inf(1)
+	}
+	return ep.limiter.Rate()
+}
+
+// Keys returns the endpoint's active signing keys, the current key last.
+func (ep *Endpoint) Keys() []signing.Key {
+	if ep.keys == nil {
+		return nil
+	}
+	return ep.keys.Keys()
+}
+
+// Subscribed reports whether the endpoint wants events of the given type.
+// An endpoint without subscriptions receives every event.
+func (ep *Endpoint) Subscribed(eventType string) bool {
+	if len(ep.Subscriptions) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(ep.Subscriptions, func(sub string) bool {
+		return matchEventType(sub, eventType)
+	})
+}
+
+// Registry holds the endpoints events are fanned out to.
+// It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	endpoints map[string]Endpoint
+	catalog   *Catalog
+	breaker   *circuit.Config
+	allowHTTP bool
+	verify    bool
+	// removed are called with the ID of every endpoint removed, to drop what was kept for it.
+	removed []func(id string)
+}
+
+// RegistryOption is a functional option for configuring Registry.
+type RegistryOption func(*Registry)
+
+// WithCatalog makes the Registry reject subscriptions to event types not in the catalog.
+func WithCatalog(c *Catalog) RegistryOption {
+	return func(r *Registry) {
+		r.catalog = c
+	}
+}
+
+// WithCircuitBreaker gives every endpoint a circuit breaker configured by cfg.
+// Deliveries to an endpoint which keeps failing are stopped while its circuit is open,
+// except for periodic probes, and the endpoint is disabled once it has been failing
+// for cfg.DisableAfter. See the circuit package.
+func WithCircuitBreaker(cfg circuit.Config) RegistryOption {
+	return func(r *Registry) {
+		r.breaker = &cfg
+	}
+}
+
+// WithAllowHTTP accepts http:// endpoint URLs, for local development.
+// By default endpoint URLs must use https.
+func WithAllowHTTP() RegistryOption {
+	return func(r *Registry) {
+		r.allowHTTP = true
+	}
+}
+
+// WithVerification requires endpoints to prove that their owner controls them:
+// added endpoints are pending and receive no events until Dispatcher.Verify succeeds.
+func WithVerification() RegistryOption {
+	return func(r *Registry) {
+		r.verify = true
+	}
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		endpoints: make(map[string]Endpoint),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Add registers an endpoint.
+func (r *Registry) Add(ep Endpoint) error {
+	if ep.ID == "" {
+		return errors.New("dispatch: endpoint ID is required")
+	}
+	if ep.URL == "" {
+		return fmt.Errorf("dispatch: endpoint %s: URL is required", ep.ID)
+	}
+	if err := r.validateURL(ep.URL); err != nil {
+		return fmt.Errorf("%w: endpoint %s: %v", ErrInvalidURL, ep.ID, err)
+	}
+	keys, err := signing.NewKeyRing(ep.Secret)
+	if err != nil {
+		return fmt.Errorf("dispatch: endpoint %s: invalid secret: %w", ep.ID, err)
+	}
+	ep.keys = keys
+	for _, sub := range ep.Subscriptions {
+		if err := validatePattern(sub); err != nil {
+			return fmt.Errorf("dispatch: endpoint %s: %w", ep.ID, err)
+		}
+	}
+	if r.catalog != nil {
+		if err := r.catalog.Validate(ep.Subscriptions); err != nil {
+			return fmt.Errorf("dispatch: endpoint %s: %w", ep.ID, err)
+		}
+	}
+	for eventType, v := range ep.Versions {
+		if v < 1 {
+			return fmt.Errorf("dispatch: endpoint %s: invalid version %d for %s", ep.ID, v, eventType)
+		}
+		if r.catalog != nil && !r.catalog.Has(eventType) {
+			return fmt.Errorf("dispatch: endpoint %s: version pinned for %q which is not in the catalog", ep.ID, eventType)
+		}
+	}
+	if ep.RateLimit < 0 || ep.Burst < 0 || ep.MaxInFlight < 0 {
+		return fmt.Errorf("dispatch: endpoint %s: limits must not be negative", ep.ID)
+	}
+	if ep.RateLimit > 0 || ep.MaxInFlight > 0 {
+		ep.limiter = ratelimit.New(ep.RateLimit, ep.Burst, ep.MaxInFlight)
+	}
+	if r.breaker != nil {
+		ep.breaker = circuit.New(*r.breaker)
+	}
+	ep.pending = r.verify && ep.VerifiedAt.IsZero()
+	ep.Subscriptions = slices.Clone(ep.Subscriptions)
+	ep.Versions = maps.Clone(ep.Versions)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.endpoints[ep.ID]; ok {
+		return ErrEndpointExists
+	}
+	r.endpoints[ep.ID] = ep
+	return nil
+}
+
+// validateURL checks that an endpoint URL is an absolute http(s) URL without credentials,
+// using https unless the Registry allows http.
+func (r *Registry) validateURL(raw string) error {
+	if len(raw) > MaxURLLength {
+		return fmt.Errorf("longer than %d characters", MaxURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && r.allowHTTP:
+	default:
+		return fmt.Errorf("scheme must be https, not %q", u.Scheme)
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return errors.New("host is required")
+	}
+	if u.User != nil {
+		return errors.New("credentials are not allowed")
+	}
+	if u.Fragment != "" {
+		return errors.New("fragments are not allowed")
+	}
+	return nil
+}
+
+// verified records that the endpoint passed verification at the given time and activates it.
+func (r *Registry) verified(id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ep, ok := r.endpoints[id]
+	if !ok {
+		return ErrEndpointNotFound
+	}
+	ep.VerifiedAt = at
+	ep.pending = false
+	r.endpoints[id] = ep
+	return nil
+}
+
+// RotateSecret generates a new signing secret for the endpoint and returns the key
+// the customer verifies with: the whsec_ secret, or the whpk_ public key for Ed25519 endpoints.
+// Deliveries are signed with both the new and the previous secrets until overlap
+// has elapsed, so the customer can switch their receiver to the new secret without downtime.
+func (r *Registry) RotateSecret(id string, overlap time.Duration) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ep, ok := r.endpoints[id]
+	if !ok {
+		return "", ErrEndpointNotFound
+	}
+	verifyKey, err := ep.keys.Rotate(overlap)
+	if err != nil {
+		return "", err
+	}
+	ep.Secret = ep.keys.Current()
+	r.endpoints[id] = ep
+	return verifyKey, nil
+}
+
+// Disable stops deliveries to the endpoint until Enable is called, recording reason.
+// It is a no-op for an endpoint without a circuit breaker.
+func (r *Registry) Disable(id, reason string) error {
+	ep, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if ep.breaker != nil {
+		ep.breaker.Disable(reason)
+	}
+	return nil
+}
+
+// Enable resumes deliveries to an endpoint which was disabled or whose circuit is open.
+func (r *Registry) Enable(id string) error {
+	ep, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	if ep.breaker != nil {
+		ep.breaker.Reset()
+	}
+	return nil
+}
+
+// Remove unregisters the endpoint with the given ID.
+func (r *Registry) Remove(id string) error {
+	r.mu.Lock()
+	if _, ok := r.endpoints[id]; !ok {
+		r.mu.Unlock()
+		return ErrEndpointNotFound
+	}
+	delete(r.endpoints, id)
+	removed := r.removed
+	r.mu.Unlock()
+
+	for _, fn := range removed {
+		fn(id)
+	}
+	return nil
+}
+
+// onRemove makes Remove call fn with the ID of every endpoint removed.
+func (r *Registry) onRemove(fn func(id string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.removed = append(r.removed, fn)
+}
+
+// Get returns the endpoint with the given ID.
+func (r *Registry) Get(id string) (Endpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ep, ok := r.endpoints[id]
+	if !ok {
+		return Endpoint{}, ErrEndpointNotFound
+	}
+	return ep, nil
+}
+
+// List returns all endpoints ordered by ID.
+func (r *Registry) List() []Endpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	eps := make([]Endpoint, 0, len(r.endpoints))
+	for _, ep := range r.endpoints {
+		eps = append(eps, ep)
+	}
+	slices.SortFunc(eps, func(a, b Endpoint) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return eps
+}
@@ -0,0 +1,73 @@
+package dispatch
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+)
+
+// ErrVerificationFailed is returned when an endpoint does not echo the verification challenge.
+var ErrVerificationFailed = errors.New("dispatch: endpoint verification failed")
+
+// Verify proves that the owner of an endpoint controls it: it sends a signed
+// endpoint.verification event with a random challenge, and activates the endpoint
+// if it responds with the same challenge in its WebhookResponse.
+// Otherwise the endpoint stays pending and an error wrapping ErrVerificationFailed,
+// or the delivery error, is returned.
+func (d *Dispatcher) Verify(ctx context.Context, endpointID string) error {
+	ep, err := d.registry.Get(endpointID)
+	if err != nil {
+		return err
+	}
+
+	challenge, err := newChallenge()
+	if err != nil {
+		return err
+	}
+	event := client.NewEndpointVerificationEvent(challenge)
+
+	wc := d.clientFor(ep)
+	body, err := wc.Encode(event)
+	if err != nil {
+		return err
+	}
+	res, err := wc.SendRaw(ctx, client.MessageID(event), body)
+	if err != nil {
+		return err
+	}
+
+	switch r := res.(type) {
+	case *api.WebhookResponse:
+		echoed, ok := r.Challenge.Get()
+		if !ok {
+			return fmt.Errorf("%w: endpoint %s: no challenge in response", ErrVerificationFailed, ep.ID)
+		}
+		if subtle.ConstantTimeCompare([]byte(echoed), []byte(challenge)) != 1 {
+			return fmt.Errorf("%w: endpoint %s: wrong challenge in response", ErrVerificationFailed, ep.ID)
+		}
+	case *api.UserEventBadRequest:
+		return fmt.Errorf("%w: endpoint %s: %s", ErrVerificationFailed, ep.ID, r.Error)
+	case *api.UserEventUnauthorized:
+		return fmt.Errorf("%w: endpoint %s: %s", ErrVerificationFailed, ep.ID, r.Error)
+	default:
+		return fmt.Errorf("%w: endpoint %s: unknown response type %T", ErrVerificationFailed, ep.ID, r)
+	}
+
+	return d.registry.verified(ep.ID, time.Now())
+}
+
+// newChallenge returns a random verification challenge.
+func newChallenge() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "chl_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,154 @@
+// Package jsonl implements the append-only JSON Lines files used by the file-backed stores.
+//
+// Every record is written as a single line and synced before Append returns.
+// A crash can therefore only leave an incomplete last line, which Read ignores.
+// Stores compact their file with Rewrite when they are opened, which also drops
+// such an incomplete line before new records are appended. Append-only stores
+// skip it instead: OpenWriter ends it, so that it stays a line of its own.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Read calls fn with every complete line of the file at path.
+// A missing file is treated as empty.
+func Read(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// An unterminated last line is an interrupted write.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(line) <= 1 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+}
+
+// Rewrite atomically replaces the file at path with one line per value.
+func Rewrite[T any](path string, values []T) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Writer appends records to a JSON Lines file.
+type Writer struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenWriter opens the file at path for appending, creating it if needed.
+// An incomplete last line is ended, so that new records start on a line of their own.
+func OpenWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	partial, err := endsPartial(path)
+	if err == nil && partial {
+		_, err = f.Write([]byte{'\n'})
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Writer{f: f}, nil
+}
+
+// endsPartial reports whether the file at path ends with an incomplete line.
+func endsPartial(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// Append writes v as a single line and syncs it to disk.
+func (w *Writer) Append(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.f.Write(b); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// Stat returns the FileInfo of the file being appended to.
+func (w *Writer) Stat() (os.FileInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Stat()
+}
+
+// Close closes the underlying file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
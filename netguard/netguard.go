@@ -0,0 +1,145 @@
+// Package netguard protects webhook delivery against server-side request forgery (SSRF).
+//
+// A customer-registered URL must not make us call internal services, so a Dialer
+// refuses to connect to loopback, private, link-local (including cloud metadata
+// endpoints such as 169.254.169.254) and other non-public addresses.
+// The check runs on the resolved address right before each connection is made,
+// so a hostname which resolves to a public address when the URL is registered
+// and to an internal one later (DNS rebinding) is still blocked.
+package netguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// blockedPrefixes are non-public ranges not covered by the netip.Addr predicates used in Blocked.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT, also used for metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which may translate to any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+	netip.MustParsePrefix("100::/64"),        // discard-only
+}
+
+// Blocked reports whether addr is not a public unicast address.
+// IPv4-mapped IPv6 addresses are checked as IPv4 addresses.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return true
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedError is returned when a connection to a blocked address is refused.
+type BlockedError struct {
+	Addr netip.Addr
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("netguard: connection to non-public address %s blocked", e.Addr)
+}
+
+// Dialer makes connections to public addresses only, apart from an allowlist.
+type Dialer struct {
+	dialer       net.Dialer
+	allowHosts   map[string]bool
+	allowNetwork []netip.Prefix
+}
+
+// New creates a Dialer which additionally allows connecting to the given hosts,
+// IP addresses and CIDR ranges, such as "localhost" for local development.
+// An allowed hostname is dialed without checking the addresses it resolves to.
+func New(allow ...string) (*Dialer, error) {
+	d := &Dialer{
+		dialer: net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		allowHosts: make(map[string]bool),
+	}
+	d.dialer.Control = d.control
+	for _, a := range allow {
+		a = strings.TrimSpace(a)
+		switch {
+		case a == "":
+		case strings.Contains(a, "/"):
+			p, err := netip.ParsePrefix(a)
+			if err != nil {
+				return nil, fmt.Errorf("netguard: invalid allowed range %q: %w", a, err)
+			}
+			d.allowNetwork = append(d.allowNetwork, p.Masked())
+		default:
+			if addr, err := netip.ParseAddr(a); err == nil {
+				d.allowNetwork = append(d.allowNetwork, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			} else {
+				d.allowHosts[strings.ToLower(a)] = true
+			}
+		}
+	}
+	return d, nil
+}
+
+// DialContext connects to address like net.Dialer.DialContext,
+// failing with a *BlockedError if it resolves to a blocked address.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if d.allowHosts[strings.ToLower(strings.TrimSuffix(host, "."))] {
+		plain := d.dialer
+		plain.Control = nil
+		return plain.DialContext(ctx, network, address)
+	}
+	return d.dialer.DialContext(ctx, network, address)
+}
+
+// control checks the resolved address of each connection attempt before it is made.
+func (d *Dialer) control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := ap.Addr().Unmap()
+	for _, p := range d.allowNetwork {
+		if p.Contains(addr) {
+			return nil
+		}
+	}
+	if Blocked(addr) {
+		return &BlockedError{Addr: addr}
+	}
+	return nil
+}
+
+// Transport returns an HTTP transport dialing through d.
+// It ignores proxy settings, since a proxy would make the connections instead.
+func (d *Dialer) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	return t
+}
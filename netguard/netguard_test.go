@@ -0,0 +1,158 @@
+package netguard
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		// loopback
+		{addr: "127.0.0.1", want: true},
+		{addr: "127.255.0.1", want: true},
+		{addr: "::1", want: true},
+		// unspecified and "this" network
+		{addr: "0.0.0.0", want: true},
+		{addr: "0.1.2.3", want: true},
+		{addr: "::", want: true},
+		// RFC 1918
+		{addr: "10.0.0.1", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "172.31.255.255", want: true},
+		{addr: "192.168.1.1", want: true},
+		// link-local, including cloud metadata
+		{addr: "169.254.169.254", want: true},
+		{addr: "fe80::1", want: true},
+		// carrier-grade NAT, e.g. Alibaba Cloud metadata
+		{addr: "100.64.0.1", want: true},
+		{addr: "100.100.100.200", want: true},
+		// unique local, site-local and multicast IPv6
+		{addr: "fc00::1", want: true},
+		{addr: "fd00:ec2::254", want: true},
+		{addr: "fec0::1", want: true},
+		{addr: "ff02::1", want: true},
+		// IPv4 multicast, reserved and broadcast
+		{addr: "224.0.0.1", want: true},
+		{addr: "240.0.0.1", want: true},
+		{addr: "255.255.255.255", want: true},
+		// IPv4-mapped IPv6
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "::ffff:10.0.0.1", want: true},
+		{addr: "::ffff:169.254.169.254", want: true},
+		// NAT64 and 6to4 embed IPv4 addresses
+		{addr: "64:ff9b::a9fe:a9fe", want: true},
+		{addr: "64:ff9b::808:808", want: true},
+		{addr: "64:ff9b:1::1", want: true},
+		{addr: "2002:7f00:1::1", want: true},
+		// documentation and benchmarking
+		{addr: "192.0.2.1", want: true},
+		{addr: "198.18.0.1", want: true},
+		{addr: "2001:db8::1", want: true},
+		// public
+		{addr: "8.8.8.8", want: false},
+		{addr: "1.1.1.1", want: false},
+		{addr: "172.32.0.1", want: false},
+		{addr: "100.128.0.1", want: false},
+		{addr: "::ffff:8.8.8.8", want: false},
+		{addr: "2606:4700:4700::1111", want: false},
+	}
+	for _, tt := range tests {
+		if got := Blocked(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Blocked(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if !Blocked(netip.Addr{}) {
+		t.Error("Blocked(invalid address) = false, want true")
+	}
+}
+
+// newTestServer starts a server on the loopback address and returns its port.
+func newTestServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "ok")
+})
+
+// get requests url through a Dialer allowing allow and reports whether the connection was blocked.
+func get(t *testing.T, url string, allow ...string) (blocked bool) {
+	t.Helper()
+	d, err := New(allow...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &http.Client{Transport: d.Transport()}
+	resp, err := c.Get(url)
+	if err != nil {
+		var be *BlockedError
+		if errors.As(err, &be) {
+			return true
+		}
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return false
+}
+
+func TestDialerAllowlist(t *testing.T) {
+	port := newTestServer(t, okHandler)
+	tests := []struct {
+		name  string
+		host  string
+		allow []string
+		want  bool
+	}{
+		{name: "loopback", host: "127.0.0.1", want: true},
+		{name: "allowed address", host: "127.0.0.1", allow: []string{"127.0.0.1"}, want: false},
+		{name: "allowed mapped address", host: "127.0.0.1", allow: []string{"::ffff:127.0.0.1"}, want: false},
+		{name: "allowed range", host: "127.0.0.1", allow: []string{"127.0.0.0/8"}, want: false},
+		{name: "other range", host: "127.0.0.1", allow: []string{"10.0.0.0/8"}, want: true},
+		{name: "hostname resolved at dial time", host: "localhost", want: true},
+		{name: "allowed hostname", host: "localhost", allow: []string{"localhost"}, want: false},
+		{name: "allowed hostname with other case", host: "LOCALHOST", allow: []string{"localhost"}, want: false},
+		{name: "allowed hostname doesn't allow its address", host: "127.0.0.1", allow: []string{"localhost"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(t, "http://"+net.JoinHostPort(tt.host, port)+"/", tt.allow...); got != tt.want {
+				t.Errorf("blocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialerRedirect(t *testing.T) {
+	// An allowed endpoint redirects to an internal address, which is checked when it is dialed
+	internal := newTestServer(t, okHandler)
+	public := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://"+net.JoinHostPort("127.0.0.1", internal)+"/", http.StatusFound)
+	}))
+
+	if !get(t, "http://"+net.JoinHostPort("localhost", public)+"/", "localhost") {
+		t.Error("redirect to an internal address was followed")
+	}
+}
+
+func TestNewInvalidAllowlist(t *testing.T) {
+	for _, allow := range []string{"10.0.0.0/33", "not a range/8"} {
+		if _, err := New(allow); err == nil {
+			t.Errorf("New(%q) succeeded", allow)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/internal/jsonl"
+)
+
+// Maximum number of response body bytes kept in a dead letter.
+const maxDeadLetterBody = 4 << 10 // 4 KiB
+
+// DeadLetter is a message whose delivery failed permanently.
+type DeadLetter struct {
+	MsgID            string            `json:"msg_id"`
+	Event            *api.WebhookEvent `json:"event"`
+	LastStatusCode   int               `json:"last_status_code,omitempty"`
+	LastResponseBody string            `json:"last_response_body,omitempty"`
+	Attempts         []Attempt         `json:"attempts"`
+	FailedAt         time.Time         `json:"failed_at"`
+}
+
+// DeadLetterStore keeps dead letters until they are redriven or deleted.
+type DeadLetterStore interface {
+	// Add stores a dead letter, replacing any previous one with the same message ID.
+	Add(ctx context.Context, dl *DeadLetter) error
+	// Get returns the dead letter with the given message ID.
+	Get(ctx context.Context, msgID string) (*DeadLetter, error)
+	// List returns all dead letters, oldest first.
+	List(ctx context.Context) ([]*DeadLetter, error)
+	// Delete removes the dead letter with the given message ID.
+	Delete(ctx context.Context, msgID string) error
+}
+
+// WithDeadLetterStore makes the Dispatcher move permanently failed messages to dlq.
+func WithDeadLetterStore(dlq DeadLetterStore) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.dlq = dlq
+	}
+}
+
+// Redrive moves the given dead letters back into the outbox for delivery.
+// The original message ID is kept, so receivers can still deduplicate the event.
+func Redrive(ctx context.Context, dlq DeadLetterStore, store Store, msgIDs ...string) error {
+	for _, id := range msgIDs {
+		dl, err := dlq.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := store.Enqueue(ctx, dl.MsgID, dl.Event); err != nil {
+			return err
+		}
+		if err := dlq.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedriveAll moves every dead letter back into the outbox and returns how many were moved.
+func RedriveAll(ctx context.Context, dlq DeadLetterStore, store Store) (int, error) {
+	dls, err := dlq.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, dl := range dls {
+		if err := Redrive(ctx, dlq, store, dl.MsgID); err != nil {
+			return i, err
+		}
+	}
+	return len(dls), nil
+}
+
+// deadLetterRecord is a line of the dead letter log.
+// A record without an entry deletes the dead letter.
+type deadLetterRecord struct {
+	MsgID string      `json:"msg_id"`
+	Entry *DeadLetter `json:"entry,omitempty"`
+}
+
+// FileDeadLetterStore is a DeadLetterStore backed by a JSON Lines log.
+type FileDeadLetterStore struct {
+	mu      sync.Mutex
+	path    string
+	w       *jsonl.Writer
+	entries map[string]*DeadLetter
+}
+
+var _ DeadLetterStore = (*FileDeadLetterStore)(nil)
+
+// OpenFileDeadLetterStore opens or creates a FileDeadLetterStore at path.
+func OpenFileDeadLetterStore(path string) (*FileDeadLetterStore, error) {
+	s := &FileDeadLetterStore{
+		path:    path,
+		entries: make(map[string]*DeadLetter),
+	}
+
+	err := jsonl.Read(path, func(line []byte) error {
+		var rec deadLetterRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		if rec.Entry == nil {
+			delete(s.entries, rec.MsgID)
+		} else {
+			s.entries[rec.MsgID] = rec.Entry
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Compact the log to the live entries
+	records := make([]deadLetterRecord, 0, len(s.entries))
+	for _, dl := range s.sorted() {
+		records = append(records, deadLetterRecord{MsgID: dl.MsgID, Entry: dl})
+	}
+	if err := jsonl.Rewrite(path, records); err != nil {
+		return nil, err
+	}
+	if s.w, err = jsonl.OpenWriter(path); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add stores a dead letter.
+func (s *FileDeadLetterStore) Add(ctx context.Context, dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.w.Append(deadLetterRecord{MsgID: dl.MsgID, Entry: dl}); err != nil {
+		return err
+	}
+	s.entries[dl.MsgID] = dl
+	return nil
+}
+
+// Get returns the dead letter with the given message ID.
+func (s *FileDeadLetterStore) Get(ctx context.Context, msgID string) (*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dl, ok := s.entries[msgID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return dl, nil
+}
+
+// List returns all dead letters, oldest first.
+func (s *FileDeadLetterStore) List(ctx context.Context) ([]*DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sorted(), nil
+}
+
+// Delete removes the dead letter with the given message ID.
+func (s *FileDeadLetterStore) Delete(ctx context.Context, msgID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[msgID]; !ok {
+		return ErrNotFound
+	}
+	if err := s.w.Append(deadLetterRecord{MsgID: msgID}); err != nil {
+		return err
+	}
+	delete(s.entries, msgID)
+	return nil
+}
+
+// Close closes the underlying log file.
+func (s *FileDeadLetterStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+func (s *FileDeadLetterStore) sorted() []*DeadLetter {
+	dls := make([]*DeadLetter, 0, len(s.entries))
+	for _, dl := range s.entries {
+		dls = append(dls, dl)
+	}
+	slices.SortFunc(dls, func(a, b *DeadLetter) int {
+		return a.FailedAt.Compare(b.FailedAt)
+	})
+	return dls
+}
+
+// truncateBody limits a response body to maxDeadLetterBody bytes.
+func truncateBody(body []byte) string {
+	if len(body) > maxDeadLetterBody {
+		body = body[:maxDeadLetterBody]
+	}
+	return string(body)
+}
@@ -0,0 +1,198 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/circuit"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+)
+
+// Sender delivers a webhook event. *client.WebhookClient satisfies this interface.
+type Sender interface {
+	SendWebhook(ctx context.Context, msgID string, event *api.WebhookEvent) (api.UserEventRes, error)
+}
+
+var _ Sender = (*client.WebhookClient)(nil)
+
+// DispatcherOption is a functional option for configuring Dispatcher.
+type DispatcherOption func(*Dispatcher)
+
+// WithRetryPolicy sets how often and how late failed deliveries are attempted again.
+// Retries are scheduled in the store, so they survive process restarts.
+func WithRetryPolicy(policy client.RetryPolicy) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.retry = policy
+	}
+}
+
+// WithPollInterval sets how often Run looks for due messages.
+func WithPollInterval(interval time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithBatchSize sets how many due messages are loaded from the store at once.
+func WithBatchSize(n int) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.batchSize = n
+	}
+}
+
+// Dispatcher drains an outbox Store through a Sender,
+// recording every attempt and its outcome in the store.
+//
+// The Sender should not retry on its own: the Dispatcher schedules retries
+// in the store according to its retry policy instead.
+type Dispatcher struct {
+	store     Store
+	sender    Sender
+	retry     client.RetryPolicy
+	interval  time.Duration
+	batchSize int
+	dlq       DeadLetterStore
+}
+
+// NewDispatcher creates a Dispatcher delivering messages from store with sender.
+func NewDispatcher(store Store, sender Sender, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store:     store,
+		sender:    sender,
+		retry:     client.DefaultRetryPolicy(),
+		interval:  time.Second,
+		batchSize: 100,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Run delivers due messages until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Drain(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain attempts every message which is currently due, then returns.
+// Messages rescheduled for a later attempt are left in the store.
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	for {
+		due, err := d.store.Due(ctx, time.Now(), d.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		for _, m := range due {
+			if err := d.deliver(ctx, m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver makes one attempt to deliver m and records the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, m *Message) error {
+	attempt := Attempt{At: time.Now()}
+	res, err := d.sender.SendWebhook(ctx, m.ID, m.Event)
+	if ctx.Err() != nil {
+		// Interrupted by shutdown; the message is still pending.
+		return ctx.Err()
+	}
+
+	if err == nil {
+		var body []byte
+		switch r := res.(type) {
+		case *api.WebhookResponse:
+			attempt.StatusCode = 200
+			m.Attempts = append(m.Attempts, attempt)
+			m.State = StateDelivered
+			return d.store.Update(ctx, m)
+		case *api.UserEventBadRequest:
+			attempt.StatusCode = 400
+			err = errors.New(r.Error)
+			body, _ = r.MarshalJSON()
+		case *api.UserEventUnauthorized:
+			attempt.StatusCode = 401
+			err = errors.New(r.Error)
+			body, _ = r.MarshalJSON()
+		default:
+			err = fmt.Errorf("unknown response type: %T", r)
+		}
+		// The receiver rejected the message; sending it again won't help.
+		attempt.Error = err.Error()
+		m.Attempts = append(m.Attempts, attempt)
+		return d.fail(ctx, m, body)
+	}
+
+	var oe *circuit.OpenError
+	if errors.As(err, &oe) {
+		// Nothing was sent; wait for the endpoint's next probe without using up an attempt.
+		m.NextAttemptAt = oe.RetryAt
+		return d.store.Update(ctx, m)
+	}
+
+	attempt.Error = err.Error()
+	var se *client.UnexpectedStatusError
+	if errors.As(err, &se) {
+		attempt.StatusCode = se.StatusCode
+	}
+	m.Attempts = append(m.Attempts, attempt)
+
+	if !client.Retryable(err) || len(m.Attempts) >= d.retry.MaxAttempts {
+		var body []byte
+		if se != nil {
+			body = se.Body
+		}
+		return d.fail(ctx, m, body)
+	}
+
+	delay := d.retry.Backoff(len(m.Attempts))
+	if ra, ok := client.RetryAfter(err); ok && ra > delay {
+		delay = ra
+	}
+	m.NextAttemptAt = time.Now().Add(delay)
+	return d.store.Update(ctx, m)
+}
+
+// fail marks m as permanently failed and moves it to the dead letter store, if any.
+func (d *Dispatcher) fail(ctx context.Context, m *Message, body []byte) error {
+	if d.dlq != nil {
+		last := m.Attempts[len(m.Attempts)-1]
+		dl := &DeadLetter{
+			MsgID:            m.ID,
+			Event:            m.Event,
+			LastStatusCode:   last.StatusCode,
+			LastResponseBody: truncateBody(body),
+			Attempts:         m.Attempts,
+			FailedAt:         time.Now(),
+		}
+		// Store the dead letter first: if this fails the message stays pending
+		// and is attempted again instead of being lost.
+		if err := d.dlq.Add(ctx, dl); err != nil {
+			return err
+		}
+	}
+
+	m.State = StateFailed
+	return d.store.Update(ctx, m)
+}
@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/client"
+	"github.com/naoyafurudono/hello-std-webhooks/internal/jsonl"
+)
+
+// DefaultRetention is how long a FileStore remembers delivered messages by default.
+const DefaultRetention = 7 * 24 * time.Hour
+
+// FileStoreOption is a functional option for configuring FileStore.
+type FileStoreOption func(*FileStore)
+
+// WithRetention sets how long delivered messages are remembered after their delivery,
+// so that enqueueing their ID again does not send them twice.
+// If none is specified, DefaultRetention is used.
+func WithRetention(d time.Duration) FileStoreOption {
+	return func(s *FileStore) {
+		s.retention = d
+	}
+}
+
+// FileStore is a Store backed by a write-ahead log in JSON Lines format.
+// Every change appends the full message to the log before it becomes visible,
+// so the latest state of each message can be recovered after a crash.
+// The log is compacted when the store is opened and as it grows. Compaction
+// shrinks delivered messages to their ID, state and times, and drops them
+// once the retention has elapsed.
+type FileStore struct {
+	mu        sync.Mutex
+	path      string
+	w         *jsonl.Writer
+	messages  map[string]*Message
+	appended  int
+	retention time.Duration
+}
+
+var _ Store = (*FileStore)(nil)
+
+// OpenFileStore opens or creates a FileStore at path.
+func OpenFileStore(path string, opts ...FileStoreOption) (*FileStore, error) {
+	s := &FileStore{
+		path:      path,
+		messages:  make(map[string]*Message),
+		retention: DefaultRetention,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Replay the log; the last record of each message wins
+	err := jsonl.Read(path, func(line []byte) error {
+		var m Message
+		if err := json.Unmarshal(line, &m); err != nil {
+			return err
+		}
+		s.messages[m.ID] = &m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Enqueue stores the event for delivery under msgID.
+func (s *FileStore) Enqueue(ctx context.Context, msgID string, event *api.WebhookEvent) (*Message, error) {
+	// Every attempt sends the stored event, so its ID and timestamp are fixed here
+	event = client.Stamp(event, "")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if m, ok := s.messages[msgID]; ok {
+		if m.State != StateFailed {
+			return m.clone(), nil
+		}
+		// Give a failed message another chance with a fresh retry budget
+		m = m.clone()
+		m.Event = event
+		m.State = StatePending
+		m.Attempts = nil
+		m.NextAttemptAt = now
+		m.UpdatedAt = now
+		if err := s.putLocked(m); err != nil {
+			return nil, err
+		}
+		return m.clone(), nil
+	}
+
+	m := &Message{
+		ID:            msgID,
+		Event:         event,
+		State:         StatePending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.putLocked(m); err != nil {
+		return nil, err
+	}
+	return m.clone(), nil
+}
+
+// Get returns the message with the given ID.
+// The Event and Attempts of a delivered message may have been dropped by compaction.
+func (s *FileStore) Get(ctx context.Context, id string) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return m.clone(), nil
+}
+
+// Due returns up to limit pending messages whose next attempt is at or before now.
+func (s *FileStore) Due(ctx context.Context, now time.Time, limit int) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*Message
+	for _, m := range s.messages {
+		if m.State == StatePending && !m.NextAttemptAt.After(now) {
+			due = append(due, m)
+		}
+	}
+	slices.SortFunc(due, func(a, b *Message) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i, m := range due {
+		due[i] = m.clone()
+	}
+	return due, nil
+}
+
+// Update stores the new state of a message.
+func (s *FileStore) Update(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.messages[msg.ID]; !ok {
+		return ErrNotFound
+	}
+	m := msg.clone()
+	m.UpdatedAt = time.Now()
+	return s.putLocked(m)
+}
+
+// Close closes the underlying log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+// putLocked appends m to the log and then makes it visible.
+func (s *FileStore) putLocked(m *Message) error {
+	if err := s.w.Append(m); err != nil {
+		return err
+	}
+	s.messages[m.ID] = m
+
+	// Compact once the log is mostly made of stale records.
+	// Compaction is only an optimization: the log stays valid if it fails.
+	s.appended++
+	if s.appended > 1024 && s.appended > 2*len(s.messages) {
+		_ = s.compactLocked()
+	}
+	return nil
+}
+
+// compactLocked rewrites the log with the latest state of every message,
+// keeping only a tombstone of delivered messages until the retention has elapsed.
+func (s *FileStore) compactLocked() error {
+	expired := time.Now().Add(-s.retention)
+	messages := make([]*Message, 0, len(s.messages))
+	for id, m := range s.messages {
+		if m.State == StateDelivered {
+			if m.UpdatedAt.Before(expired) {
+				delete(s.messages, id)
+				continue
+			}
+			m = m.tombstone()
+			s.messages[id] = m
+		}
+		messages = append(messages, m)
+	}
+	slices.SortFunc(messages, func(a, b *Message) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	if err := jsonl.Rewrite(s.path, messages); err != nil {
+		return err
+	}
+
+	// The rewritten log replaced the old one, so reopen the writer.
+	w, err := jsonl.OpenWriter(s.path)
+	if err != nil {
+		return err
+	}
+	if s.w != nil {
+		s.w.Close()
+	}
+	s.w = w
+	s.appended = 0
+	return nil
+}
@@ -0,0 +1,154 @@
+package outbox
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+func testEvent() *api.WebhookEvent {
+	e := api.NewUserDeletedEventWebhookEvent(api.UserDeletedEvent{
+		ID:        "evt_1",
+		Type:      "user.deleted",
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		Data:      api.UserDeletedData{ID: "user_1"},
+	})
+	return &e
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     State
+		retention time.Duration
+		// want is the state of the message enqueued again after reopening
+		want State
+	}{
+		{name: "pending", state: StatePending, retention: time.Hour, want: StatePending},
+		{name: "delivered", state: StateDelivered, retention: time.Hour, want: StateDelivered},
+		{name: "delivered after retention", state: StateDelivered, retention: -time.Second, want: StatePending},
+		{name: "failed", state: StateFailed, retention: time.Hour, want: StatePending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+			s, err := OpenFileStore(path, WithRetention(tt.retention))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := s.Enqueue(ctx, "msg_1", testEvent())
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.State = tt.state
+			m.Attempts = []Attempt{{At: time.Now(), StatusCode: 200}}
+			if err := s.Update(ctx, m); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+
+			s, err = OpenFileStore(path, WithRetention(tt.retention))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			m, err = s.Enqueue(ctx, "msg_1", testEvent())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.State != tt.want {
+				t.Errorf("state after reopen and enqueue = %s, want %s", m.State, tt.want)
+			}
+
+			due, err := s.Due(ctx, time.Now(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wantDue := tt.want == StatePending; (len(due) == 1) != wantDue {
+				t.Errorf("%d messages due, want due: %v", len(due), wantDue)
+			}
+		})
+	}
+}
+
+func TestFileStoreKeepsPendingEvents(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Enqueue(ctx, "msg_1", testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	m, err := s.Get(ctx, "msg_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Event == nil || eventID(m.Event) != "evt_1" {
+		t.Errorf("event after reopen = %+v, want evt_1", m.Event)
+	}
+}
+
+// eventID returns the ID of a user.deleted event.
+func eventID(e *api.WebhookEvent) string {
+	d, ok := e.GetUserDeletedEvent()
+	if !ok {
+		return ""
+	}
+	return d.ID
+}
+
+func TestFileStoreStampsEvents(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unstamped := api.NewUserDeletedEventWebhookEvent(api.UserDeletedEvent{
+		Type: "user.deleted",
+		Data: api.UserDeletedData{ID: "user_1"},
+	})
+	m, err := s.Enqueue(ctx, "msg_1", &unstamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	stamped, _ := m.Event.GetUserDeletedEvent()
+	if stamped.ID == "" || stamped.Timestamp.IsZero() {
+		t.Fatalf("enqueued event is not stamped: %+v", stamped)
+	}
+
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	m, err = s.Get(ctx, "msg_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The log keeps timestamps to the second, like the requests do
+	got, _ := m.Event.GetUserDeletedEvent()
+	if got.ID != stamped.ID || !got.Timestamp.Equal(stamped.Timestamp.Truncate(time.Second)) {
+		t.Errorf("event after reopen = %s at %s, want %s at %s", got.ID, got.Timestamp, stamped.ID, stamped.Timestamp)
+	}
+}
@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// ErrNotFound is returned when a message does not exist in the store.
+var ErrNotFound = errors.New("outbox: message not found")
+
+// State is the delivery state of a message.
+type State string
+
+const (
+	// StatePending means the message is waiting for its next delivery attempt.
+	StatePending State = "pending"
+	// StateDelivered means the receiver accepted the message.
+	StateDelivered State = "delivered"
+	// StateFailed means the message will not be attempted again.
+	StateFailed State = "failed"
+)
+
+// Attempt records the outcome of a single delivery attempt.
+type Attempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Message is a webhook event stored in the outbox together with its message ID.
+// The ID is sent as webhook-id on every attempt so receivers can deduplicate retries.
+type Message struct {
+	ID            string            `json:"id"`
+	Event         *api.WebhookEvent `json:"event"`
+	State         State             `json:"state"`
+	Attempts      []Attempt         `json:"attempts,omitempty"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// clone returns a copy of m which can be modified without affecting the store.
+func (m *Message) clone() *Message {
+	c := *m
+	c.Attempts = append([]Attempt(nil), m.Attempts...)
+	return &c
+}
+
+// tombstone returns what is kept of a delivered message: its ID, state and times.
+func (m *Message) tombstone() *Message {
+	return &Message{
+		ID:        m.ID,
+		State:     m.State,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+// Store persists outbox messages.
+type Store interface {
+	// Enqueue stores the event for delivery under msgID, stamped with client.Stamp,
+	// so that every attempt sends the same event ID and timestamp.
+	// Enqueueing an ID which is already pending or delivered returns the existing message,
+	// while a failed message is made pending again with its attempts reset.
+	// Stores may forget delivered messages after a retention period.
+	Enqueue(ctx context.Context, msgID string, event *api.WebhookEvent) (*Message, error)
+	// Get returns the message with the given ID.
+	Get(ctx context.Context, id string) (*Message, error)
+	// Due returns up to limit pending messages whose next attempt is at or before now,
+	// oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]*Message, error)
+	// Update stores the new state of a message.
+	Update(ctx context.Context, msg *Message) error
+}
+
+// NewMessageID returns a new random message ID.
+func NewMessageID() string {
+	return "msg_" + uuid.New().String()
+}
@@ -0,0 +1,153 @@
+// Package ratelimit limits how fast and how many requests at a time are sent to one endpoint.
+//
+// A Limiter combines a token bucket with a cap on in-flight requests. Callers
+// over the limits wait in line rather than being rejected, and a 429 response
+// lowers the rate, which then recovers gradually as requests succeed.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// Rate used after a 429 from an endpoint without a rate limit.
+	throttledRate = 1
+	// Lowest rate a Limiter is lowered to.
+	minRate = 0.1
+	// Factor by which the rate recovers after each successful request.
+	recoveryFactor = 1.1
+	// Rate above which an endpoint without a rate limit becomes unlimited again.
+	maxRecoveredRate = 1000
+)
+
+// Limiter limits the requests sent to one endpoint. It is safe for concurrent use.
+type Limiter struct {
+	bucket   *rate.Limiter
+	inFlight chan struct{} // nil for no cap
+
+	mu          sync.Mutex
+	base        rate.Limit // configured rate
+	pausedUntil time.Time
+}
+
+// New creates a Limiter allowing perSecond requests per second with bursts of burst requests,
+// and at most maxInFlight requests at a time.
+// A zero perSecond or maxInFlight means no limit; burst defaults to 1.
+func New(perSecond float64, burst, maxInFlight int) *Limiter {
+	base := rate.Inf
+	if perSecond > 0 {
+		base = rate.Limit(perSecond)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	l := &Limiter{
+		bucket: rate.NewLimiter(base, burst),
+		base:   base,
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// Acquire waits until a request may be sent: the endpoint is not paused,
+// a token is available and fewer than the maximum requests are in flight.
+// The returned function must be called when the request is done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	for {
+		if err := l.waitPause(ctx); err != nil {
+			return nil, err
+		}
+		if err := l.bucket.Wait(ctx); err != nil {
+			return nil, err
+		}
+		release = func() {}
+		if l.inFlight != nil {
+			select {
+			case l.inFlight <- struct{}{}:
+				release = func() { <-l.inFlight }
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if !l.paused() {
+			return release, nil
+		}
+		// A pause began while waiting; wait for it and take a token at the lowered rate.
+		release()
+	}
+}
+
+func (l *Limiter) paused() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().Before(l.pausedUntil)
+}
+
+func (l *Limiter) waitPause(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		wait := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Throttled lowers the rate after the endpoint answered 429 Too Many Requests:
+// requests are paused for retryAfter, if positive, and the rate is halved.
+func (l *Limiter) Throttled(retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(retryAfter); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	limit := l.bucket.Limit()
+	if limit == rate.Inf {
+		limit = throttledRate
+	} else {
+		limit /= 2
+	}
+	l.bucket.SetLimit(max(limit, minRate))
+}
+
+// Succeeded lets a lowered rate recover towards the configured one after a successful request.
+func (l *Limiter) Succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.bucket.Limit()
+	if limit >= l.base {
+		return
+	}
+	limit *= recoveryFactor
+	if l.base == rate.Inf && limit > maxRecoveredRate {
+		limit = rate.Inf
+	}
+	l.bucket.SetLimit(min(limit, l.base))
+}
+
+// Rate returns the current rate in requests per second, which is +Inf for no limit.
+func (l *Limiter) Rate() float64 {
+	limit := l.bucket.Limit()
+	if limit == rate.Inf {
+		return math.Inf(1)
+	}
+	return float64(limit)
+}
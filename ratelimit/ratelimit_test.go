@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name      string
+		perSecond float64
+		// ops is a sequence of t for Throttled and s for Succeeded
+		ops  string
+		want float64
+	}{
+		{name: "unlimited", perSecond: 0, ops: "", want: inf},
+		{name: "limited", perSecond: 10, ops: "", want: 10},
+		{name: "succeeded at the configured rate", perSecond: 10, ops: "s", want: 10},
+		{name: "throttled unlimited", perSecond: 0, ops: "t", want: 1},
+		{name: "throttled halves", perSecond: 10, ops: "tt", want: 2.5},
+		{name: "throttled no lower than the minimum", perSecond: 0.3, ops: "ttt", want: 0.1},
+		{name: "succeeded recovers", perSecond: 10, ops: "ts", want: 5.5},
+		{name: "recovers no higher than the configured rate", perSecond: 10, ops: "t" + strings.Repeat("s", 10), want: 10},
+		{name: "unlimited recovers to unlimited", perSecond: 0, ops: "t" + strings.Repeat("s", 100), want: inf},
+		{name: "throttled while recovering", perSecond: 10, ops: "tst", want: 2.75},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.perSecond, 1, 0)
+			for _, op := range tt.ops {
+				switch op {
+				case 't':
+					l.Throttled(0)
+				case 's':
+					l.Succeeded()
+				}
+			}
+			if got := l.Rate(); got != tt.want && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("rate after %q = %v, want %v", tt.ops, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterThrottledPauses(t *testing.T) {
+	const pause = 50 * time.Millisecond
+	l := New(0, 1, 0)
+	l.Throttled(pause)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pause/5)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire during the pause = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	start := time.Now()
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if elapsed := time.Since(start); elapsed < pause/2 {
+		t.Errorf("Acquire returned after %s, want it to wait for the pause", elapsed)
+	}
+}
@@ -0,0 +1,170 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+const (
+	userCreated = `{"id":"evt_1","type":"user.created","timestamp":"2025-01-01T12:00:00Z","data":{"id":"user_1","email":"user@example.com","name":"User"}}`
+	userDeleted = `{"id":"evt_2","type":"user.deleted","timestamp":"2025-01-01T12:00:00Z","data":{"id":"user_1"}}`
+)
+
+// countingHandler counts the events it handles and fails user.deleted events.
+type countingHandler struct {
+	BaseEventHandler
+	calls atomic.Int32
+}
+
+func (h *countingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.calls.Add(1)
+	return nil
+}
+
+func (h *countingHandler) UserDeleted(ctx context.Context, event *api.UserDeletedEvent) error {
+	h.calls.Add(1)
+	return errors.New("failed")
+}
+
+// signedRequest returns a request with body signed by signer at now.
+func signedRequest(t *testing.T, signer signing.Signer, msgID string, now time.Time, body string) *http.Request {
+	t.Helper()
+	sig, err := signer.Sign(msgID, now, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("webhook-id", msgID)
+	req.Header.Set("webhook-timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("webhook-signature", sig)
+	return req
+}
+
+// newSecret returns a new whsec_ secret and its signer.
+func newSecret(t *testing.T) (string, signing.Signer) {
+	t.Helper()
+	secret, err := signing.GenerateSecret(signing.DefaultSecretBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, signer
+}
+
+func TestReceiverBatch(t *testing.T) {
+	secret, signer := newSecret(t)
+	h := &countingHandler{}
+	rc, err := NewWithSecret(secret, Typed(h))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	rc.ServeHTTP(w, signedRequest(t, signer, "msg_1", time.Now(), "["+userCreated+","+userDeleted+"]"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+	var res api.BatchResponse
+	if err := res.UnmarshalJSON(w.Body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		id      string
+		success bool
+		err     string
+	}{
+		{id: "evt_1", success: true},
+		{id: "evt_2", success: false, err: http.StatusText(http.StatusInternalServerError)},
+	}
+	if len(res.Results) != len(want) {
+		t.Fatalf("%d results, want %d", len(res.Results), len(want))
+	}
+	for i, w := range want {
+		got := res.Results[i]
+		if got.ID != w.id || got.Success != w.success || got.Error.Or("") != w.err {
+			t.Errorf("result %d = %s %v %q, want %s %v %q", i, got.ID, got.Success, got.Error.Or(""), w.id, w.success, w.err)
+		}
+	}
+}
+
+func TestReceiverDuplicate(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		calls int32
+		// check checks the response to the duplicate
+		check func(t *testing.T, body []byte)
+	}{
+		{
+			name:  "single event",
+			body:  userCreated,
+			calls: 1,
+			check: func(t *testing.T, body []byte) {
+				var res api.WebhookResponse
+				if err := res.UnmarshalJSON(body); err != nil {
+					t.Fatal(err)
+				}
+				if !res.Success || res.Message != DuplicateMessage {
+					t.Errorf("response = %+v, want a duplicate acknowledgement", res)
+				}
+			},
+		},
+		{
+			name:  "batch",
+			body:  "[" + userCreated + "," + userCreated + "]",
+			calls: 2,
+			check: func(t *testing.T, body []byte) {
+				var res api.BatchResponse
+				if err := res.UnmarshalJSON(body); err != nil {
+					t.Fatal(err)
+				}
+				if len(res.Results) != 2 {
+					t.Fatalf("%d results, want 2", len(res.Results))
+				}
+				for i, r := range res.Results {
+					if r.ID != "evt_1" || !r.Success {
+						t.Errorf("result %d = %+v, want evt_1 handled", i, r)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, signer := newSecret(t)
+			h := &countingHandler{}
+			rc, err := NewWithSecret(secret, Typed(h), WithReplayStore(NewMemoryReplayStore(100, time.Hour)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var w *httptest.ResponseRecorder
+			for range 2 {
+				w = httptest.NewRecorder()
+				rc.ServeHTTP(w, signedRequest(t, signer, "msg_1", time.Now(), tt.body))
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+				}
+			}
+			tt.check(t, w.Body.Bytes())
+			if n := h.calls.Load(); n != tt.calls {
+				t.Errorf("handler called %d times, want %d", n, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// EventHandler handles verified webhook events by type.
+// Returning an error responds with 500, so the sender retries the event.
+type EventHandler interface {
+	UserCreated(ctx context.Context, event *api.UserCreatedEvent) error
+	UserUpdated(ctx context.Context, event *api.UserUpdatedEvent) error
+	UserDeleted(ctx context.Context, event *api.UserDeletedEvent) error
+}
+
+// BaseEventHandler acknowledges every event without doing anything.
+// Embed it in an EventHandler to handle only some event types.
+type BaseEventHandler struct{}
+
+var _ EventHandler = BaseEventHandler{}
+
+// UserCreated acknowledges the event.
+func (BaseEventHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	return nil
+}
+
+// UserUpdated acknowledges the event.
+func (BaseEventHandler) UserUpdated(ctx context.Context, event *api.UserUpdatedEvent) error {
+	return nil
+}
+
+// UserDeleted acknowledges the event.
+func (BaseEventHandler) UserDeleted(ctx context.Context, event *api.UserDeletedEvent) error {
+	return nil
+}
+
+// Typed adapts an EventHandler to api.WebhookHandler, calling the method for the event's type.
+func Typed(h EventHandler) api.WebhookHandler {
+	return typedHandler{h: h}
+}
+
+type typedHandler struct {
+	h EventHandler
+}
+
+func (t typedHandler) UserEvent(ctx context.Context, req api.WebhookEvent) (api.UserEventRes, error) {
+	if req.Type == api.EndpointVerificationEventWebhookEvent {
+		return VerificationResponse(&req.EndpointVerificationEvent), nil
+	}
+
+	if err := t.handle(ctx, &req); err != nil {
+		var unsupported *unsupportedTypeError
+		if errors.As(err, &unsupported) {
+			return &api.UserEventBadRequest{Error: err.Error()}, nil
+		}
+		return nil, err
+	}
+
+	return &api.WebhookResponse{
+		Success: true,
+		Message: "Webhook received and verified successfully",
+	}, nil
+}
+
+// UserEventBatch handles every event of the batch on its own and reports the outcome of each.
+// Handler errors are not sent back, like the 500 responses to single events.
+func (t typedHandler) UserEventBatch(ctx context.Context, req api.WebhookEventBatch) (api.UserEventBatchRes, error) {
+	res := &api.BatchResponse{Results: make([]api.BatchEventResult, len(req))}
+	for i := range req {
+		result := api.BatchEventResult{ID: eventID(&req[i]), Success: true}
+		if err := t.handle(ctx, &req[i]); err != nil {
+			result.Success = false
+			var unsupported *unsupportedTypeError
+			if errors.As(err, &unsupported) {
+				result.Error = api.NewOptString(err.Error())
+			} else {
+				result.Error = api.NewOptString(http.StatusText(http.StatusInternalServerError))
+			}
+		}
+		res.Results[i] = result
+	}
+	return res, nil
+}
+
+// handle calls the EventHandler method for the event's type.
+// Verification events are only answered in single requests and are acknowledged in batches.
+func (t typedHandler) handle(ctx context.Context, event *api.WebhookEvent) error {
+	switch event.Type {
+	case api.EndpointVerificationEventWebhookEvent:
+		return nil
+	case api.UserCreatedEventWebhookEvent:
+		return t.h.UserCreated(ctx, &event.UserCreatedEvent)
+	case api.UserUpdatedEventWebhookEvent:
+		return t.h.UserUpdated(ctx, &event.UserUpdatedEvent)
+	case api.UserDeletedEventWebhookEvent:
+		return t.h.UserDeleted(ctx, &event.UserDeletedEvent)
+	default:
+		return &unsupportedTypeError{eventType: string(event.Type)}
+	}
+}
+
+// VerificationResponse returns the response to an endpoint.verification event,
+// echoing its challenge so the sender activates the endpoint.
+// Handlers created with Typed respond to verification events with it on their own.
+func VerificationResponse(event *api.EndpointVerificationEvent) *api.WebhookResponse {
+	return &api.WebhookResponse{
+		Success:   true,
+		Message:   "Endpoint verified",
+		Challenge: api.NewOptString(event.Data.Challenge),
+	}
+}
+
+type unsupportedTypeError struct {
+	eventType string
+}
+
+func (e *unsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported event type: %s", e.eventType)
+}
+
+// eventID returns the envelope ID of the event.
+func eventID(event *api.WebhookEvent) string {
+	switch event.Type {
+	case api.UserCreatedEventWebhookEvent:
+		return event.UserCreatedEvent.ID
+	case api.UserUpdatedEventWebhookEvent:
+		return event.UserUpdatedEvent.ID
+	case api.UserDeletedEventWebhookEvent:
+		return event.UserDeletedEvent.ID
+	case api.EndpointVerificationEventWebhookEvent:
+		return event.EndpointVerificationEvent.ID
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,334 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ogen-go/ogen/ogenerrors"
+	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/cloudevents"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+	"github.com/naoyafurudono/hello-std-webhooks/versioning"
+)
+
+// Default limit for the size of a webhook request body.
+const defaultMaxBodySize = 1 << 20 // 1 MiB
+
+// DuplicateMessage is the WebhookResponse message sent for duplicate deliveries.
+const DuplicateMessage = "Duplicate delivery ignored"
+
+// Verifier verifies standard-webhooks signature headers against the raw payload.
+// *standardwebhooks.Webhook and *signing.Ed25519Verifier satisfy this interface.
+type Verifier = signing.Verifier
+
+// Option is a functional option for configuring Receiver.
+type Option func(*Receiver)
+
+// WithMaxBodySize limits the size of request bodies accepted by the Receiver.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Receiver) {
+		r.maxBodySize = n
+	}
+}
+
+// WithServerOptions passes options to the underlying api.WebhookServer.
+// They are applied after the Receiver's defaults, so they may override the error handler.
+func WithServerOptions(opts ...api.ServerOption) Option {
+	return func(r *Receiver) {
+		r.serverOpts = append(r.serverOpts, opts...)
+	}
+}
+
+// WithVersions upcasts events of older schema versions to the current version in vr
+// before they are decoded, so handlers only deal with the current payload shape.
+func WithVersions(vr *versioning.Registry) Option {
+	return func(r *Receiver) {
+		r.versions = vr
+	}
+}
+
+// WithCloudEvents accepts events sent as CloudEvents 1.0 in binary or structured mode,
+// as well as plain webhook events. The signature is verified over the bytes received,
+// then the CloudEvent is mapped back to a webhook event for the handler.
+func WithCloudEvents() Option {
+	return func(r *Receiver) {
+		r.cloudEvents = true
+	}
+}
+
+// WithoutTracePropagation ignores the W3C traceparent, tracestate and baggage headers
+// of incoming webhooks, so that senders can't make the handler's spans part of their traces.
+// By default the span of the generated server is a child of the sender's span.
+func WithoutTracePropagation() Option {
+	return func(r *Receiver) {
+		r.propagator = nil
+	}
+}
+
+// Receiver is an http.Handler that verifies standard-webhooks signatures
+// before passing the request to the generated api.WebhookServer.
+// Single events and batches are accepted on the same URL: a JSON array body
+// is handled as a userEventBatch request.
+// Note: Verification can't be done in an ogen middleware because the signature
+// covers the exact bytes sent by the sender, so the raw body is buffered here
+// and replayed to the generated server after the signature has been checked.
+type Receiver struct {
+	verifier     Verifier
+	handler      http.Handler
+	batchHandler http.Handler
+	maxBodySize  int64
+	serverOpts   []api.ServerOption
+	replay       ReplayStore
+	versions     *versioning.Registry
+	cloudEvents  bool
+	propagator   propagation.TextMapPropagator
+}
+
+// New creates a Receiver that verifies requests with verifier and calls h for verified events.
+func New(verifier Verifier, h api.WebhookHandler, opts ...Option) (*Receiver, error) {
+	r := &Receiver{
+		verifier:    verifier,
+		maxBodySize: defaultMaxBodySize,
+		serverOpts:  []api.ServerOption{api.WithErrorHandler(errorHandler)},
+		propagator:  propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	server, err := api.NewWebhookServer(h, r.serverOpts...)
+	if err != nil {
+		return nil, err
+	}
+	r.handler = server.Handler("userEvent")
+	r.batchHandler = server.Handler("userEventBatch")
+
+	return r, nil
+}
+
+// NewWithSecret creates a Receiver that verifies requests with a base64-encoded secret key (whsec_),
+// or with an Ed25519 public key (whpk_) for asymmetric v1a signatures.
+func NewWithSecret(secret string, h api.WebhookHandler, opts ...Option) (*Receiver, error) {
+	verifier, err := signing.NewVerifier(secret)
+	if err != nil {
+		return nil, err
+	}
+	return New(verifier, h, opts...)
+}
+
+// ServeHTTP verifies the webhook signature and dispatches the request to the handler.
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Let the generated server reject unsupported methods.
+	if r.Method != http.MethodPost {
+		rc.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// Buffer the raw body, which is needed for signature verification
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, rc.maxBodySize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+
+	// Verify the webhook signature
+	if err := rc.verifier.Verify(body, r.Header); err != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid webhook signature")
+		return
+	}
+
+	// Map CloudEvents back to the webhook events the handler decodes
+	if rc.cloudEvents && cloudevents.IsCloudEvent(r.Header) {
+		if body, err = cloudevents.Decode(r.Header, body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Header = r.Header.Clone()
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	msgID := r.Header.Get(standardwebhooks.HeaderWebhookID)
+	handled := false
+
+	// Reject replays of messages which were already handled or are being handled
+	if rc.replay != nil {
+		seen, err := rc.replay.Reserve(r.Context(), msgID)
+		if err != nil {
+			// Let the sender retry rather than risk handling the message twice.
+			writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		if seen {
+			writeDuplicate(w, body)
+			return
+		}
+
+		// Only remember messages which were handled successfully,
+		// so that failed deliveries can still be retried by the sender.
+		defer func() {
+			if !handled {
+				// A failure here only makes the sender's retry look like a duplicate until the ID expires.
+				_ = rc.replay.Release(context.WithoutCancel(r.Context()), msgID)
+			}
+		}()
+	}
+
+	// Upcast the verified body to the version the handler decodes
+	if rc.versions != nil {
+		if body, err = rc.upcast(body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Replay the verified body to the generated server
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	ctx := context.WithValue(r.Context(), messageIDKey{}, msgID)
+	if rc.propagator != nil {
+		// Continue the sender's trace, only for requests it has signed
+		ctx = rc.propagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	}
+	sw := &statusWriter{ResponseWriter: w}
+	if isBatch(body) {
+		rc.batchHandler.ServeHTTP(sw, r.WithContext(ctx))
+	} else {
+		rc.handler.ServeHTTP(sw, r.WithContext(ctx))
+	}
+	handled = sw.status >= 200 && sw.status < 300
+}
+
+// isBatch reports whether the body is a userEventBatch request, a JSON array of events.
+func isBatch(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// upcast converts an encoded event, or each event of a batch, older than the current version of its type.
+// Bodies which aren't events are returned as is for the generated server to reject.
+func (rc *Receiver) upcast(body []byte) ([]byte, error) {
+	if isBatch(body) {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return body, nil
+		}
+		for i, event := range batch {
+			upcasted, err := rc.upcast(event)
+			if err != nil {
+				return nil, err
+			}
+			batch[i] = upcasted
+		}
+		return json.Marshal(batch)
+	}
+
+	eventType, version, err := versioning.Version(body)
+	if err != nil {
+		return body, nil
+	}
+	if current := rc.versions.Current(eventType); version < current {
+		return rc.versions.ConvertEvent(body, current)
+	}
+	return body, nil
+}
+
+// statusWriter records the status code written by the handler.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type messageIDKey struct{}
+
+// MessageID returns the verified webhook-id of the request being handled.
+// It can be used by handlers as an idempotency key.
+func MessageID(ctx context.Context) string {
+	id, _ := ctx.Value(messageIDKey{}).(string)
+	return id
+}
+
+// errorHandler writes errors from the generated server as ErrorResponse.
+func errorHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	code := ogenerrors.ErrorCode(err)
+	msg := err.Error()
+	if code >= http.StatusInternalServerError {
+		// Don't leak handler internals to the sender.
+		msg = http.StatusText(code)
+	}
+	writeError(w, code, msg)
+}
+
+// writeDuplicate acknowledges a message which was already handled.
+// It responds with 200 so that the sender stops retrying: a WebhookResponse
+// for a single event, or a BatchResponse reporting every event as handled for a batch.
+func writeDuplicate(w http.ResponseWriter, req []byte) {
+	var res json.Marshaler = &api.WebhookResponse{Success: true, Message: DuplicateMessage}
+	if isBatch(req) {
+		res = duplicateBatch(req)
+	}
+	body, err := res.MarshalJSON()
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// duplicateBatch returns the response to a batch which was already handled.
+func duplicateBatch(req []byte) *api.BatchResponse {
+	var events []struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(req, &events)
+
+	res := &api.BatchResponse{Results: make([]api.BatchEventResult, len(events))}
+	for i, event := range events {
+		res.Results[i] = api.BatchEventResult{ID: event.ID, Success: true}
+	}
+	return res
+}
+
+// writeError writes an ErrorResponse with the given status code.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	body, err := (&api.ErrorResponse{Error: msg}).MarshalJSON()
+	if err != nil {
+		http.Error(w, msg, code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
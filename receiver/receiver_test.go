@@ -0,0 +1,119 @@
+package receiver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReceiverVerify(t *testing.T) {
+	secret, signer := newSecret(t)
+	_, otherSigner := newSecret(t)
+
+	tests := []struct {
+		name string
+		// req returns the request to send
+		req  func(t *testing.T) *http.Request
+		want int
+	}{
+		{
+			name: "valid signature",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "tampered body",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+				tampered := bytes.Replace([]byte(userCreated), []byte("user@example.com"), []byte("evil@example.com"), 1)
+				req.Body = io.NopCloser(bytes.NewReader(tampered))
+				return req
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered webhook-id",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+				req.Header.Set("webhook-id", "msg_2")
+				return req
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "expired timestamp",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, signer, "msg_1", time.Now().Add(-time.Hour), userCreated)
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "future timestamp",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, signer, "msg_1", time.Now().Add(time.Hour), userCreated)
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "other secret",
+			req: func(t *testing.T) *http.Request {
+				return signedRequest(t, otherSigner, "msg_1", time.Now(), userCreated)
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "missing signature",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+				req.Header.Del("webhook-signature")
+				return req
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "missing webhook-id",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+				req.Header.Del("webhook-id")
+				return req
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "missing timestamp",
+			req: func(t *testing.T) *http.Request {
+				req := signedRequest(t, signer, "msg_1", time.Now(), userCreated)
+				req.Header.Del("webhook-timestamp")
+				return req
+			},
+			want: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &countingHandler{}
+			rc, err := NewWithSecret(secret, Typed(h))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			rc.ServeHTTP(w, tt.req(t))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			wantCalls := int32(0)
+			if tt.want == http.StatusOK {
+				wantCalls = 1
+			}
+			if n := h.calls.Load(); n != wantCalls {
+				t.Errorf("handler called %d times, want %d", n, wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package receiver
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/internal/jsonl"
+)
+
+// ReplayStore remembers the webhook-id of delivered messages.
+// The Receiver claims the ID after signature verification and before calling the
+// handler, so that a captured request replayed within the timestamp tolerance, or a
+// copy arriving while the original is still being handled, is not handled twice.
+//
+// The TTL of a store should be longer than the timestamp tolerance (5 minutes),
+// otherwise a replayed request may pass both checks.
+type ReplayStore interface {
+	// Reserve claims the message ID. It reports true if the ID was already claimed,
+	// in which case the message must not be handled again. Checking and claiming is atomic.
+	Reserve(ctx context.Context, id string) (alreadySeen bool, err error)
+	// Release drops the claim of a message which was not handled successfully,
+	// so that the sender can retry it.
+	Release(ctx context.Context, id string) error
+}
+
+// WithReplayStore enables replay protection using the given store.
+// Duplicate deliveries are answered with 200 without calling the handler,
+// so senders stop retrying them.
+func WithReplayStore(store ReplayStore) Option {
+	return func(r *Receiver) {
+		r.replay = store
+	}
+}
+
+// MemoryReplayStore is an in-memory ReplayStore with LRU eviction and TTL.
+type MemoryReplayStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+}
+
+type replayEntry struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var _ ReplayStore = (*MemoryReplayStore)(nil)
+
+// NewMemoryReplayStore creates a MemoryReplayStore holding at most capacity IDs for ttl.
+func NewMemoryReplayStore(capacity int, ttl time.Duration) *MemoryReplayStore {
+	return &MemoryReplayStore{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Reserve claims the message ID, evicting the least recently used IDs if the store is full.
+// It reports true if the ID is already claimed and has not expired.
+func (s *MemoryReplayStore) Reserve(ctx context.Context, id string) (bool, error) {
+	_, seen := s.reserve(id, time.Now())
+	return seen, nil
+}
+
+// reserve claims the message ID unless it is claimed, and returns the new entry.
+func (s *MemoryReplayStore) reserve(id string, now time.Time) (replayEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[id]; ok {
+		if now.Before(el.Value.(*replayEntry).ExpiresAt) {
+			s.order.MoveToFront(el)
+			return replayEntry{}, true
+		}
+		s.remove(el)
+	}
+	e := replayEntry{ID: id, ExpiresAt: now.Add(s.ttl)}
+	s.addLocked(e.ID, e.ExpiresAt)
+	return e, false
+}
+
+// Release drops the claim of the message ID.
+func (s *MemoryReplayStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[id]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+func (s *MemoryReplayStore) add(id string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addLocked(id, expiresAt)
+}
+
+func (s *MemoryReplayStore) addLocked(id string, expiresAt time.Time) {
+	if el, ok := s.entries[id]; ok {
+		el.Value.(*replayEntry).ExpiresAt = expiresAt
+		s.order.MoveToFront(el)
+		return
+	}
+	s.entries[id] = s.order.PushFront(&replayEntry{ID: id, ExpiresAt: expiresAt})
+
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *MemoryReplayStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*replayEntry).ID)
+}
+
+func (s *MemoryReplayStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// expire drops the expired entries and returns the others, oldest first.
+func (s *MemoryReplayStore) expire(now time.Time) []replayEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]replayEntry, 0, s.order.Len())
+	for el := s.order.Back(); el != nil; {
+		prev := el.Prev()
+		if e := el.Value.(*replayEntry); now.Before(e.ExpiresAt) {
+			entries = append(entries, *e)
+		} else {
+			s.remove(el)
+		}
+		el = prev
+	}
+	return entries
+}
+
+// FileReplayStore is a ReplayStore persisted to a JSON Lines file,
+// so replay protection survives receiver restarts.
+type FileReplayStore struct {
+	mem      *MemoryReplayStore
+	path     string
+	mu       sync.Mutex
+	w        *jsonl.Writer
+	appended int // records appended since the last compaction
+	live     int // records kept by the last compaction
+}
+
+var _ ReplayStore = (*FileReplayStore)(nil)
+
+// OpenFileReplayStore opens or creates a FileReplayStore at path.
+// IDs are remembered for ttl; expired and released IDs are dropped when the file is compacted.
+func OpenFileReplayStore(path string, ttl time.Duration) (*FileReplayStore, error) {
+	s := &FileReplayStore{
+		mem:  NewMemoryReplayStore(0, ttl),
+		path: path,
+	}
+
+	err := jsonl.Read(path, func(line []byte) error {
+		var e replayEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		s.mem.add(e.ID, e.ExpiresAt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reserve claims the message ID and appends the claim to the file.
+func (s *FileReplayStore) Reserve(ctx context.Context, id string) (bool, error) {
+	e, seen := s.mem.reserve(id, time.Now())
+	if seen {
+		return true, nil
+	}
+	if err := s.append(&e); err != nil {
+		// Without the claim on disk a restart would forget it, so don't claim it at all.
+		_ = s.mem.Release(ctx, id)
+		return false, err
+	}
+	return false, nil
+}
+
+// Release drops the claim of the message ID, appending an expired record for it to the file.
+func (s *FileReplayStore) Release(ctx context.Context, id string) error {
+	_ = s.mem.Release(ctx, id)
+	return s.append(&replayEntry{ID: id})
+}
+
+func (s *FileReplayStore) append(e *replayEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Append(e); err != nil {
+		return err
+	}
+
+	// Compact once more records were appended than the last compaction kept,
+	// so the file is mostly made of expired and released records.
+	s.appended++
+	if s.appended > max(1024, s.live) {
+		return s.compactLocked()
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileReplayStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+func (s *FileReplayStore) compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compactLocked()
+}
+
+func (s *FileReplayStore) compactLocked() error {
+	live := s.mem.expire(time.Now())
+	if err := jsonl.Rewrite(s.path, live); err != nil {
+		return err
+	}
+
+	// The rewritten file replaced the old one, so reopen the writer.
+	w, err := jsonl.OpenWriter(s.path)
+	if err != nil {
+		return err
+	}
+	if s.w != nil {
+		s.w.Close()
+	}
+	s.w = w
+	s.appended = 0
+	s.live = len(live)
+	return nil
+}
@@ -0,0 +1,265 @@
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+	"github.com/naoyafurudono/hello-std-webhooks/signing"
+)
+
+func TestReplayStoreReserve(t *testing.T) {
+	stores := map[string]func(t *testing.T) ReplayStore{
+		"memory": func(t *testing.T) ReplayStore {
+			return NewMemoryReplayStore(100, time.Hour)
+		},
+		"file": func(t *testing.T) ReplayStore {
+			s, err := OpenFileReplayStore(filepath.Join(t.TempDir(), "replay.jsonl"), time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s := open(t)
+
+			steps := []struct {
+				op   string
+				id   string
+				seen bool
+			}{
+				{op: "reserve", id: "msg_1", seen: false},
+				{op: "reserve", id: "msg_1", seen: true},
+				{op: "reserve", id: "msg_2", seen: false},
+				{op: "release", id: "msg_1"},
+				{op: "reserve", id: "msg_1", seen: false},
+				{op: "reserve", id: "msg_2", seen: true},
+			}
+			for i, step := range steps {
+				switch step.op {
+				case "reserve":
+					seen, err := s.Reserve(ctx, step.id)
+					if err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+					if seen != step.seen {
+						t.Errorf("step %d: Reserve(%s) = %v, want %v", i, step.id, seen, step.seen)
+					}
+				case "release":
+					if err := s.Release(ctx, step.id); err != nil {
+						t.Fatalf("step %d: %v", i, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReplayStoreReserveConcurrent(t *testing.T) {
+	s := NewMemoryReplayStore(0, time.Hour)
+
+	var claimed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen, err := s.Reserve(context.Background(), "msg_x")
+			if err != nil {
+				t.Error(err)
+			}
+			if !seen {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := claimed.Load(); n != 1 {
+		t.Errorf("%d reservations succeeded, want 1", n)
+	}
+}
+
+func TestFileReplayStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "replay.jsonl")
+
+	s, err := OpenFileReplayStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"msg_1", "msg_2", "msg_3"} {
+		if _, err := s.Reserve(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Release(ctx, "msg_2"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFileReplayStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		id   string
+		seen bool
+	}{
+		{id: "msg_1", seen: true},
+		{id: "msg_2", seen: false}, // released
+		{id: "msg_3", seen: true},
+	}
+	for _, tt := range tests {
+		seen, err := s.Reserve(ctx, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != tt.seen {
+			t.Errorf("Reserve(%s) after reopen = %v, want %v", tt.id, seen, tt.seen)
+		}
+	}
+}
+
+func TestFileReplayStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "replay.jsonl")
+
+	s, err := OpenFileReplayStore(path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	const n = 5000
+	for i := range n {
+		if _, err := s.Reserve(ctx, "msg_"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i%1000 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	if got := s.mem.len(); got > 2048 {
+		t.Errorf("%d IDs in memory after %d reservations, want expired ones dropped", got, n)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(b, []byte("\n")); lines > 2048 {
+		t.Errorf("%d lines in the file after %d reservations, want it compacted", lines, n)
+	}
+}
+
+// blockingHandler answers every event after a delay, counting the calls.
+type blockingHandler struct {
+	BaseEventHandler
+	calls atomic.Int32
+	delay time.Duration
+	fail  atomic.Bool
+}
+
+func (h *blockingHandler) UserCreated(ctx context.Context, event *api.UserCreatedEvent) error {
+	h.calls.Add(1)
+	time.Sleep(h.delay)
+	if h.fail.Load() {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+// newTestReceiver returns a receiver calling h with a replay store, and a function sending it a signed request.
+func newTestReceiver(t *testing.T, h EventHandler) func(msgID string) int {
+	t.Helper()
+	secret, err := signing.GenerateSecret(signing.DefaultSecretBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signing.NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := NewWithSecret(secret, Typed(h), WithReplayStore(NewMemoryReplayStore(100, time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"id":"evt_1","type":"user.created","timestamp":"2025-01-01T12:00:00Z","data":{"id":"user_1","email":"user@example.com","name":"User"}}`)
+	return func(msgID string) int {
+		now := time.Now()
+		sig, err := signer.Sign(msgID, now, body)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("webhook-id", msgID)
+		req.Header.Set("webhook-timestamp", strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set("webhook-signature", sig)
+		w := httptest.NewRecorder()
+		rc.ServeHTTP(w, req)
+		return w.Code
+	}
+}
+
+func TestReceiverDeduplicatesConcurrentCopies(t *testing.T) {
+	h := &blockingHandler{delay: 100 * time.Millisecond}
+	send := newTestReceiver(t, h)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := send("msg_x"); code != http.StatusOK {
+				t.Errorf("status %d, want 200", code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := h.calls.Load(); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
+
+func TestReceiverReleasesFailedMessages(t *testing.T) {
+	h := &blockingHandler{}
+	send := newTestReceiver(t, h)
+
+	h.fail.Store(true)
+	if code := send("msg_x"); code != http.StatusInternalServerError {
+		t.Fatalf("failed delivery: status %d, want 500", code)
+	}
+	h.fail.Store(false)
+	if code := send("msg_x"); code != http.StatusOK {
+		t.Fatalf("retry: status %d, want 200", code)
+	}
+	if code := send("msg_x"); code != http.StatusOK {
+		t.Fatalf("duplicate: status %d, want 200", code)
+	}
+
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("handler called %d times, want 2: the failed delivery and its retry", n)
+	}
+}
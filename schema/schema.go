@@ -0,0 +1,243 @@
+// Package schema validates webhook event payloads against JSON Schemas at runtime,
+// so that a malformed event is rejected before it is signed and sent.
+//
+// The schema of each event type's data is taken from the discriminator mapping
+// of the OpenAPI document, or from a directory holding one JSON Schema per event type.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+// Printer for the messages of schema violations.
+var printer = message.NewPrinter(language.English)
+
+// Violation is a single way in which a payload does not match its schema.
+type Violation struct {
+	// Path is the JSON Pointer of the offending value within the event, e.g. "/data/email".
+	Path string
+	// Message describes the violation.
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError is returned for an event whose data does not match the schema of its type.
+// It lists every violation, not only the first one.
+type ValidationError struct {
+	EventType  string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("schema: invalid %s event: %s", e.EventType, strings.Join(msgs, "; "))
+}
+
+// Validator validates event data against the schema of the event type.
+// It is safe for concurrent use.
+type Validator struct {
+	schemas map[string]*jsonschema.Schema
+}
+
+// Default returns a Validator for the OpenAPI document embedded in the api package.
+func Default() (*Validator, error) {
+	return FromOpenAPI(api.OpenAPISpec)
+}
+
+// LoadOpenAPI creates a Validator from an OpenAPI document in YAML or JSON.
+func LoadOpenAPI(path string) (*Validator, error) {
+	spec, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromOpenAPI(spec)
+}
+
+// FromOpenAPI creates a Validator from an OpenAPI document in YAML or JSON.
+// The event types are the keys of the WebhookEvent discriminator mapping,
+// and the schema of each type is the data property of the schema it maps to.
+func FromOpenAPI(spec []byte) (*Validator, error) {
+	raw, err := yaml.YAMLToJSON(spec)
+	if err != nil {
+		return nil, fmt.Errorf("schema: parse OpenAPI document: %w", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("schema: parse OpenAPI document: %w", err)
+	}
+
+	var openapi struct {
+		Components struct {
+			Schemas struct {
+				WebhookEvent struct {
+					Discriminator struct {
+						Mapping map[string]string `json:"mapping"`
+					} `json:"discriminator"`
+				} `json:"WebhookEvent"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &openapi); err != nil {
+		return nil, fmt.Errorf("schema: parse OpenAPI document: %w", err)
+	}
+	mapping := openapi.Components.Schemas.WebhookEvent.Discriminator.Mapping
+	if len(mapping) == 0 {
+		return nil, errors.New("schema: OpenAPI document has no WebhookEvent discriminator mapping")
+	}
+
+	const url = "openapi.json"
+	c := newCompiler()
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+
+	v := &Validator{schemas: make(map[string]*jsonschema.Schema, len(mapping))}
+	for eventType, ref := range mapping {
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("schema: %s: unsupported reference %q", eventType, ref)
+		}
+		sch, err := c.Compile(url + ref + "/properties/data")
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s: %w", eventType, err)
+		}
+		v.schemas[eventType] = sch
+	}
+	return v, nil
+}
+
+// LoadDir creates a Validator from a directory of JSON Schemas for event data,
+// one file per event type named after it, such as "user.created.json".
+func LoadDir(dir string) (*Validator, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("schema: no schemas in %s", dir)
+	}
+
+	c := newCompiler()
+	v := &Validator{schemas: make(map[string]*jsonschema.Schema, len(paths))}
+	for _, path := range paths {
+		eventType := strings.TrimSuffix(filepath.Base(path), ".json")
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		sch, err := c.Compile(abs)
+		if err != nil {
+			return nil, fmt.Errorf("schema: %s: %w", eventType, err)
+		}
+		v.schemas[eventType] = sch
+	}
+	return v, nil
+}
+
+func newCompiler() *jsonschema.Compiler {
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	// Formats such as email are only annotations unless asserted.
+	c.AssertFormat()
+	return c
+}
+
+// Types returns the event types the Validator has schemas for, sorted.
+func (v *Validator) Types() []string {
+	types := make([]string, 0, len(v.schemas))
+	for t := range v.schemas {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Validate checks the event's data against the schema of its type.
+// It returns a *ValidationError if the data does not match or the type is unknown.
+func (v *Validator) Validate(event *api.WebhookEvent) error {
+	body, err := event.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var envelope struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return err
+	}
+	return v.ValidateData(envelope.Type, envelope.Data)
+}
+
+// ValidateData checks encoded event data against the schema of eventType.
+// Violation paths are relative to the event, so they start with "/data".
+func (v *Validator) ValidateData(eventType string, data []byte) error {
+	sch, ok := v.schemas[eventType]
+	if !ok {
+		return &ValidationError{
+			EventType:  eventType,
+			Violations: []Violation{{Path: "/type", Message: "no schema for event type"}},
+		}
+	}
+	if len(data) == 0 {
+		return &ValidationError{
+			EventType:  eventType,
+			Violations: []Violation{{Path: "/data", Message: "missing data"}},
+		}
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("schema: decode data: %w", err)
+	}
+	err = sch.Validate(inst)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	e := &ValidationError{EventType: eventType}
+	collect(verr, &e.Violations)
+	slices.SortStableFunc(e.Violations, func(a, b Violation) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return e
+}
+
+// collect appends the leaf errors of the tree, which are the actual violations.
+func collect(verr *jsonschema.ValidationError, violations *[]Violation) {
+	if len(verr.Causes) == 0 {
+		path := "/data"
+		for _, tok := range verr.InstanceLocation {
+			tok = strings.ReplaceAll(tok, "~", "~0")
+			path += "/" + strings.ReplaceAll(tok, "/", "~1")
+		}
+		*violations = append(*violations, Violation{
+			Path:    path,
+			Message: verr.ErrorKind.LocalizedString(printer),
+		})
+		return
+	}
+	for _, cause := range verr.Causes {
+		collect(cause, violations)
+	}
+}
@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/naoyafurudono/hello-std-webhooks/api"
+)
+
+func TestValidate(t *testing.T) {
+	v, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		event *api.WebhookEvent
+		// paths are the paths of the violations, none for a valid event
+		paths []string
+	}{
+		{
+			name:  "valid",
+			event: userCreated(api.UserCreatedData{ID: "user_1", Email: "user@example.com", Name: "User"}),
+		},
+		{
+			name:  "bad email",
+			event: userCreated(api.UserCreatedData{ID: "user_1", Email: "not an email", Name: "User"}),
+			paths: []string{"/data/email"},
+		},
+		{
+			name:  "empty id",
+			event: userCreated(api.UserCreatedData{ID: "", Email: "user@example.com", Name: "User"}),
+			paths: []string{"/data/id"},
+		},
+		{
+			name:  "every violation",
+			event: userCreated(api.UserCreatedData{ID: "", Email: "not an email", Name: "User"}),
+			paths: []string{"/data/email", "/data/id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.event)
+			if tt.paths == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if verr.EventType != "user.created" {
+				t.Errorf("EventType = %q, want user.created", verr.EventType)
+			}
+			var paths []string
+			for _, v := range verr.Violations {
+				paths = append(paths, v.Path)
+			}
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("violations at %v, want %v", paths, tt.paths)
+			}
+		})
+	}
+}
+
+// userCreated returns a user.created event carrying data.
+func userCreated(data api.UserCreatedData) *api.WebhookEvent {
+	event := api.NewUserCreatedEventWebhookEvent(api.UserCreatedEvent{
+		ID:        "evt_1",
+		Timestamp: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		Type:      string(api.UserCreatedEventWebhookEvent),
+		Data:      data,
+	})
+	return &event
+}
+
+func TestValidateData(t *testing.T) {
+	v, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		eventType string
+		data      string
+		path      string
+	}{
+		{name: "unknown type", eventType: "user.renamed", data: `{"id":"user_1"}`, path: "/type"},
+		{name: "missing data", eventType: "user.deleted", path: "/data"},
+		{name: "missing field", eventType: "user.deleted", data: `{}`, path: "/data"},
+		{name: "wrong type", eventType: "user.deleted", data: `{"id":1}`, path: "/data/id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verr *ValidationError
+			if err := v.ValidateData(tt.eventType, []byte(tt.data)); !errors.As(err, &verr) {
+				t.Fatalf("ValidateData() = %v, want a *ValidationError", err)
+			}
+			if len(verr.Violations) == 0 || verr.Violations[0].Path != tt.path {
+				t.Errorf("violations = %v, want one at %s", verr.Violations, tt.path)
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{"type":"object","required":["id"],"properties":{"id":{"type":"string","minLength":1}}}`
+	if err := os.WriteFile(filepath.Join(dir, "user.deleted.json"), []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types := v.Types(); !slices.Equal(types, []string{"user.deleted"}) {
+		t.Errorf("Types() = %v, want [user.deleted]", types)
+	}
+	if err := v.ValidateData("user.deleted", []byte(`{"id":"user_1"}`)); err != nil {
+		t.Errorf("valid data: %v", err)
+	}
+	if err := v.ValidateData("user.deleted", []byte(`{"id":""}`)); err == nil {
+		t.Error("empty id accepted")
+	}
+
+	if _, err := LoadDir(t.TempDir()); err == nil {
+		t.Error("LoadDir succeeded without schemas")
+	}
+}
@@ -0,0 +1,134 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+)
+
+const (
+	// PublicKeyPrefix is the prefix of Ed25519 public keys used to verify v1a signatures.
+	PublicKeyPrefix = "whpk_"
+	// PrivateKeyPrefix is the prefix of Ed25519 private keys used to create v1a signatures.
+	PrivateKeyPrefix = "whsk_"
+)
+
+// Maximum allowed difference between webhook-timestamp and now,
+// matching the standardwebhooks library.
+const tolerance = 5 * time.Minute
+
+// GenerateKeyPair returns a new Ed25519 key pair in whpk_ and whsk_ format.
+func GenerateKeyPair() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return PublicKeyPrefix + base64.StdEncoding.EncodeToString(pub),
+		PrivateKeyPrefix + base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// Ed25519Signer creates asymmetric v1a signatures.
+// Receivers verify them with the public key only, so they never hold the signing secret.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a signer from a whsk_ private key.
+// Both the 64-byte private key and its 32-byte seed are accepted.
+func NewEd25519Signer(privateKey string) (*Ed25519Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(privateKey, PrivateKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("signing: invalid private key: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.PrivateKeySize:
+		return &Ed25519Signer{key: ed25519.PrivateKey(raw)}, nil
+	case ed25519.SeedSize:
+		return &Ed25519Signer{key: ed25519.NewKeyFromSeed(raw)}, nil
+	default:
+		return nil, fmt.Errorf("signing: invalid private key length %d", len(raw))
+	}
+}
+
+// Sign returns a v1a signature of the payload.
+func (s *Ed25519Signer) Sign(msgID string, timestamp time.Time, payload []byte) (string, error) {
+	sig := ed25519.Sign(s.key, signedContent(msgID, timestamp.Unix(), payload))
+	return "v1a," + base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// PublicKey returns the whpk_ public key for verifying this signer's signatures.
+func (s *Ed25519Signer) PublicKey() string {
+	return PublicKeyPrefix + base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Ed25519Verifier verifies v1a signatures with a public key.
+type Ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier creates a verifier from a whpk_ public key.
+func NewEd25519Verifier(publicKey string) (*Ed25519Verifier, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(publicKey, PublicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("signing: invalid public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("signing: invalid public key length %d", len(raw))
+	}
+	return &Ed25519Verifier{key: ed25519.PublicKey(raw)}, nil
+}
+
+// Verify validates the payload against the webhook signature headers.
+// Like the standardwebhooks library, it rejects timestamps outside the tolerance
+// and returns its errors, so callers can handle both kinds of signatures alike.
+func (v *Ed25519Verifier) Verify(payload []byte, headers http.Header) error {
+	msgID := headers.Get(standardwebhooks.HeaderWebhookID)
+	msgSignature := headers.Get(standardwebhooks.HeaderWebhookSignature)
+	msgTimestamp := headers.Get(standardwebhooks.HeaderWebhookTimestamp)
+	if msgID == "" || msgSignature == "" || msgTimestamp == "" {
+		return fmt.Errorf("unable to verify payload, err: %w", standardwebhooks.ErrRequiredHeaders)
+	}
+
+	ts, err := strconv.ParseInt(msgTimestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to verify payload, err: %w", errors.Join(err, standardwebhooks.ErrInvalidHeaders))
+	}
+	now := time.Now()
+	timestamp := time.Unix(ts, 0)
+	if now.Sub(timestamp) > tolerance {
+		return fmt.Errorf("unable to verify payload, err: %w", standardwebhooks.ErrMessageTooOld)
+	}
+	if timestamp.After(now.Add(tolerance)) {
+		return fmt.Errorf("unable to verify payload, err: %w", standardwebhooks.ErrMessageTooNew)
+	}
+
+	content := signedContent(msgID, ts, payload)
+	for _, versioned := range strings.Split(msgSignature, " ") {
+		version, sig, ok := strings.Cut(versioned, ",")
+		if !ok || version != "v1a" {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(sig)
+		if err != nil {
+			continue
+		}
+		if ed25519.Verify(v.key, content, raw) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unable to verify payload, err: %w", standardwebhooks.ErrNoMatchingSignature)
+}
+
+// signedContent returns the bytes covered by a signature.
+func signedContent(msgID string, timestamp int64, payload []byte) []byte {
+	return fmt.Appendf(nil, "%s.%d.%s", msgID, timestamp, payload)
+}
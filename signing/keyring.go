@@ -0,0 +1,159 @@
+package signing
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNoKey       = errors.New("signing: key ring has no key")
+	errNoActiveKey = errors.New("signing: no active key")
+)
+
+// Key is a signing secret in a KeyRing.
+type Key struct {
+	// Secret is a whsec_ secret or a whsk_ private key.
+	Secret string
+	// ExpiresAt is when the key stops signing. The zero value means never.
+	ExpiresAt time.Time
+}
+
+func (k Key) active(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+type ringKey struct {
+	Key
+	signer Signer
+}
+
+// KeyRing signs messages with every active key, so that receivers can rotate
+// secrets without downtime: during the rotation window the webhook-signature
+// header carries one space-separated signature per key.
+// It is safe for concurrent use. The zero value has no keys until Add is called.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []ringKey // the last key is the current one
+}
+
+// NewKeyRing creates a KeyRing whose current key is secret.
+func NewKeyRing(secret string) (*KeyRing, error) {
+	kr := &KeyRing{}
+	if err := kr.Add(Key{Secret: secret}); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// Add adds a key to the ring and makes it the current key.
+func (kr *KeyRing) Add(key Key) error {
+	signer, err := NewSigner(key.Secret)
+	if err != nil {
+		return err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = append(kr.keys, ringKey{Key: key, signer: signer})
+	return nil
+}
+
+// Rotate generates a new current key of the same kind as the current one and
+// returns what the receiver needs to verify it: the new whsec_ secret,
+// or the new whpk_ public key when the ring signs with Ed25519.
+// The previous keys keep signing until overlap has elapsed.
+// It fails for a KeyRing without keys, whose kind of key is unknown.
+func (kr *KeyRing) Rotate(overlap time.Duration) (string, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if len(kr.keys) == 0 {
+		return "", errNoKey
+	}
+
+	var (
+		secret, verifyKey string
+		signer            Signer
+		err               error
+	)
+	if strings.HasPrefix(kr.keys[len(kr.keys)-1].Secret, PrivateKeyPrefix) {
+		verifyKey, secret, err = GenerateKeyPair()
+	} else {
+		secret, err = GenerateSecret(DefaultSecretBytes)
+		verifyKey = secret
+	}
+	if err != nil {
+		return "", err
+	}
+	if signer, err = NewSigner(secret); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(overlap)
+	keys := kr.keys[:0]
+	for _, k := range kr.keys {
+		if !k.active(now) {
+			continue
+		}
+		if k.ExpiresAt.IsZero() || k.ExpiresAt.After(expiresAt) {
+			k.ExpiresAt = expiresAt
+		}
+		keys = append(keys, k)
+	}
+	kr.keys = append(keys, ringKey{Key: Key{Secret: secret}, signer: signer})
+
+	return verifyKey, nil
+}
+
+// Current returns the signing secret of the current key, or "" for a KeyRing without keys.
+func (kr *KeyRing) Current() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.keys) == 0 {
+		return ""
+	}
+	return kr.keys[len(kr.keys)-1].Secret
+}
+
+// Keys returns the keys which are still active, the current key last.
+func (kr *KeyRing) Keys() []Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := time.Now()
+	var keys []Key
+	for _, k := range kr.keys {
+		if k.active(now) {
+			keys = append(keys, k.Key)
+		}
+	}
+	return keys
+}
+
+// Sign signs the payload with every active key and returns
+// the signatures separated by spaces, the current key first.
+func (kr *KeyRing) Sign(msgID string, timestamp time.Time, payload []byte) (string, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := time.Now()
+	var sigs []string
+	for i := len(kr.keys) - 1; i >= 0; i-- {
+		k := kr.keys[i]
+		if !k.active(now) {
+			continue
+		}
+		sig, err := k.signer.Sign(msgID, timestamp, payload)
+		if err != nil {
+			return "", err
+		}
+		sigs = append(sigs, sig)
+	}
+	if len(sigs) == 0 {
+		return "", errNoActiveKey
+	}
+	return strings.Join(sigs, " "), nil
+}
@@ -0,0 +1,132 @@
+package signing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRingRotate(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		overlap time.Duration
+		// wantOld is whether receivers still verifying with the old key accept deliveries
+		wantOld bool
+	}{
+		{name: "v1 with overlap", kind: "v1", overlap: time.Hour, wantOld: true},
+		{name: "v1 without overlap", kind: "v1", overlap: 0, wantOld: false},
+		{name: "v1a with overlap", kind: "v1a", overlap: time.Hour, wantOld: true},
+		{name: "v1a without overlap", kind: "v1a", overlap: 0, wantOld: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, oldKey := newKeys(t, tt.kind)
+			kr, err := NewKeyRing(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newKey, err := kr.Rotate(tt.overlap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if newKey == oldKey {
+				t.Fatal("Rotate returned the old key")
+			}
+			if kr.Current() == secret {
+				t.Error("the current key did not change")
+			}
+			if tt.kind == "v1a" && !strings.HasPrefix(newKey, PublicKeyPrefix) {
+				t.Errorf("Rotate returned %q, want a %s public key", newKey, PublicKeyPrefix)
+			}
+
+			keys := kr.Keys()
+			wantKeys := 1
+			if tt.wantOld {
+				wantKeys = 2
+			}
+			if len(keys) != wantKeys {
+				t.Fatalf("%d active keys, want %d", len(keys), wantKeys)
+			}
+			if keys[len(keys)-1].Secret != kr.Current() {
+				t.Error("the current key is not the last one")
+			}
+
+			now := time.Now()
+			sig, err := kr.Sign("msg_1", now, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(strings.Fields(sig)); n != wantKeys {
+				t.Errorf("%d signatures, want %d", n, wantKeys)
+			}
+
+			for _, k := range []struct {
+				key  string
+				want bool
+			}{
+				{key: newKey, want: true},
+				{key: oldKey, want: tt.wantOld},
+			} {
+				verifier, err := NewVerifier(k.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = verifier.Verify(payload, headers("msg_1", now, sig))
+				if (err == nil) != k.want {
+					t.Errorf("Verify with %.10s... = %v, want accepted: %v", k.key, err, k.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyRingRotateTwice(t *testing.T) {
+	secret, _ := newKeys(t, "v1")
+	kr, err := NewKeyRing(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Rotate(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Rotate(time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both previous keys expire with the shorter overlap
+	keys := kr.Keys()
+	if len(keys) != 3 {
+		t.Fatalf("%d active keys, want 3", len(keys))
+	}
+	for _, k := range keys[:2] {
+		if until := time.Until(k.ExpiresAt); until <= 0 || until > time.Minute {
+			t.Errorf("previous key expires in %s, want within a minute", until)
+		}
+	}
+	if !keys[2].ExpiresAt.IsZero() {
+		t.Errorf("current key expires at %s, want never", keys[2].ExpiresAt)
+	}
+}
+
+func TestKeyRingWithoutKeys(t *testing.T) {
+	var kr KeyRing
+	if _, err := kr.Rotate(time.Hour); err == nil {
+		t.Error("Rotate succeeded without a key")
+	}
+	if got := kr.Current(); got != "" {
+		t.Errorf("Current() = %q, want empty", got)
+	}
+	if _, err := kr.Sign("msg_1", time.Now(), payload); err == nil {
+		t.Error("Sign succeeded without a key")
+	}
+
+	secret, _ := newKeys(t, "v1")
+	if err := kr.Add(Key{Secret: secret}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Rotate(time.Hour); err != nil {
+		t.Errorf("Rotate after Add: %v", err)
+	}
+}
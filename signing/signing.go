@@ -0,0 +1,75 @@
+package signing
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+)
+
+const (
+	// SecretPrefix is the prefix of symmetric signing secrets.
+	SecretPrefix = "whsec_"
+	// DefaultSecretBytes is the default length of generated secrets.
+	DefaultSecretBytes = 32 // 256 bits
+)
+
+// GenerateSecret returns a new random secret of the given length in whsec_ format.
+func GenerateSecret(bytes int) (string, error) {
+	buf := make([]byte, bytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Signer signs webhook payloads and returns the webhook-signature header value.
+type Signer interface {
+	Sign(msgID string, timestamp time.Time, payload []byte) (string, error)
+}
+
+// Verifier verifies webhook signature headers against the raw payload.
+type Verifier interface {
+	Verify(payload []byte, headers http.Header) error
+}
+
+var (
+	_ Signer   = (*standardwebhooks.Webhook)(nil)
+	_ Signer   = (*Ed25519Signer)(nil)
+	_ Signer   = (*KeyRing)(nil)
+	_ Verifier = (*standardwebhooks.Webhook)(nil)
+	_ Verifier = (*Ed25519Verifier)(nil)
+)
+
+// NewSigner creates a Signer for a whsec_ secret (v1 signatures)
+// or a whsk_ private key (v1a signatures).
+func NewSigner(secret string) (Signer, error) {
+	if strings.HasPrefix(secret, PrivateKeyPrefix) {
+		return NewEd25519Signer(secret)
+	}
+	wh, err := standardwebhooks.NewWebhook(secret)
+	if err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
+
+// NewVerifier creates a Verifier for a whsec_ secret (v1 signatures)
+// or a whpk_ public key (v1a signatures).
+func NewVerifier(key string) (Verifier, error) {
+	switch {
+	case strings.HasPrefix(key, PublicKeyPrefix):
+		return NewEd25519Verifier(key)
+	case strings.HasPrefix(key, PrivateKeyPrefix):
+		return nil, fmt.Errorf("signing: verify with the %s public key, not the private key", PublicKeyPrefix)
+	}
+	wh, err := standardwebhooks.NewWebhook(key)
+	if err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
@@ -0,0 +1,152 @@
+package signing
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	standardwebhooks "github.com/standard-webhooks/standard-webhooks/libraries/go"
+)
+
+var payload = []byte(`{"id":"evt_1","type":"user.deleted"}`)
+
+// headers returns the webhook headers of a signed request.
+func headers(msgID string, timestamp time.Time, signature string) http.Header {
+	h := http.Header{}
+	h.Set(standardwebhooks.HeaderWebhookID, msgID)
+	h.Set(standardwebhooks.HeaderWebhookTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	h.Set(standardwebhooks.HeaderWebhookSignature, signature)
+	return h
+}
+
+// newKeys returns a signing secret and the key verifying it, of the given kind.
+func newKeys(t *testing.T, kind string) (secret, verifyKey string) {
+	t.Helper()
+	var err error
+	switch kind {
+	case "v1":
+		secret, err = GenerateSecret(DefaultSecretBytes)
+		verifyKey = secret
+	case "v1a":
+		verifyKey, secret, err = GenerateKeyPair()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret, verifyKey
+}
+
+func TestSignVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		// tamper changes the signed request before it is verified
+		tamper   func(h http.Header, body []byte) (http.Header, []byte)
+		otherKey bool
+		want     error
+	}{
+		{name: "valid"},
+		{
+			name: "payload changed",
+			tamper: func(h http.Header, body []byte) (http.Header, []byte) {
+				return h, append(body, ' ')
+			},
+			want: standardwebhooks.ErrNoMatchingSignature,
+		},
+		{
+			name: "webhook-id changed",
+			tamper: func(h http.Header, body []byte) (http.Header, []byte) {
+				h.Set(standardwebhooks.HeaderWebhookID, "msg_2")
+				return h, body
+			},
+			want: standardwebhooks.ErrNoMatchingSignature,
+		},
+		{
+			name: "signature missing",
+			tamper: func(h http.Header, body []byte) (http.Header, []byte) {
+				h.Del(standardwebhooks.HeaderWebhookSignature)
+				return h, body
+			},
+			want: standardwebhooks.ErrRequiredHeaders,
+		},
+		{name: "other key", otherKey: true, want: standardwebhooks.ErrNoMatchingSignature},
+	}
+	for _, kind := range []string{"v1", "v1a"} {
+		for _, tt := range tests {
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				secret, verifyKey := newKeys(t, kind)
+				if tt.otherKey {
+					_, verifyKey = newKeys(t, kind)
+				}
+				signer, err := NewSigner(secret)
+				if err != nil {
+					t.Fatal(err)
+				}
+				verifier, err := NewVerifier(verifyKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				now := time.Now()
+				sig, err := signer.Sign("msg_1", now, payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(sig, kind+",") {
+					t.Errorf("signature %q is not a %s signature", sig, kind)
+				}
+
+				h, body := headers("msg_1", now, sig), payload
+				if tt.tamper != nil {
+					h, body = tt.tamper(h, body)
+				}
+				if err := verifier.Verify(body, h); !errors.Is(err, tt.want) {
+					t.Errorf("Verify() = %v, want %v", err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestEd25519VerifyTimestamp(t *testing.T) {
+	secret, verifyKey := newKeys(t, "v1a")
+	signer, err := NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(verifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   error
+	}{
+		{name: "within tolerance", offset: -tolerance / 2},
+		{name: "too old", offset: -2 * tolerance, want: standardwebhooks.ErrMessageTooOld},
+		{name: "too new", offset: 2 * tolerance, want: standardwebhooks.ErrMessageTooNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := time.Now().Add(tt.offset)
+			sig, err := signer.Sign("msg_1", ts, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifier.Verify(payload, headers("msg_1", ts, sig)); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRejectsPrivateKey(t *testing.T) {
+	secret, _ := newKeys(t, "v1a")
+	if _, err := NewVerifier(secret); err == nil {
+		t.Error("NewVerifier accepted a private key")
+	}
+}
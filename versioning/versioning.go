@@ -0,0 +1,198 @@
+// Package versioning converts event payloads between schema versions,
+// so that senders can evolve a payload while receivers pinned to an older
+// version keep getting the shape they understand.
+//
+// Each event type has a current version, the one described by api/openapi.yaml.
+// Upcasters convert data from version n to n+1 and downcasters from n to n-1;
+// conversions between distant versions are chained one step at a time.
+package versioning
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Data is the decoded data of an event. Numbers are json.Number.
+type Data = map[string]any
+
+// Transform converts event data to the adjacent schema version.
+// It may modify data in place and return it.
+type Transform func(data Data) (Data, error)
+
+type eventVersions struct {
+	current int
+	up      map[int]Transform // from n to n+1
+	down    map[int]Transform // from n to n-1
+}
+
+// Registry holds the schema versions of event types and the transforms between them.
+// It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	events map[string]*eventVersions
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		events: make(map[string]*eventVersions),
+	}
+}
+
+// Register declares the current schema version of an event type.
+func (r *Registry) Register(eventType string, current int) error {
+	if current < 1 {
+		return fmt.Errorf("versioning: %s: invalid version %d", eventType, current)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(eventType).current = current
+	return nil
+}
+
+// AddUpcaster registers the transform of eventType data from version from to from+1.
+func (r *Registry) AddUpcaster(eventType string, from int, fn Transform) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(eventType).up[from] = fn
+}
+
+// AddDowncaster registers the transform of eventType data from version from to from-1.
+func (r *Registry) AddDowncaster(eventType string, from int, fn Transform) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(eventType).down[from] = fn
+}
+
+// get returns the versions of eventType, creating them if needed. r.mu must be held.
+func (r *Registry) get(eventType string) *eventVersions {
+	ev, ok := r.events[eventType]
+	if !ok {
+		ev = &eventVersions{
+			current: 1,
+			up:      make(map[int]Transform),
+			down:    make(map[int]Transform),
+		}
+		r.events[eventType] = ev
+	}
+	return ev
+}
+
+// Current returns the current schema version of eventType, which is 1 if it isn't registered.
+func (r *Registry) Current(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if ev, ok := r.events[eventType]; ok {
+		return ev.current
+	}
+	return 1
+}
+
+// Types returns the registered event types, sorted.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.events))
+	for t := range r.events {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Convert transforms eventType data from version from to version to,
+// applying one upcaster or downcaster per version step.
+func (r *Registry) Convert(eventType string, data Data, from, to int) (Data, error) {
+	if from < 1 || to < 1 {
+		return nil, fmt.Errorf("versioning: %s: cannot convert v%d to v%d", eventType, from, to)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ev := r.events[eventType]
+	for v := from; v != to; {
+		var (
+			fn   Transform
+			next int
+		)
+		if ev != nil && v < to {
+			fn, next = ev.up[v], v+1
+		} else if ev != nil {
+			fn, next = ev.down[v], v-1
+		}
+		if fn == nil {
+			return nil, fmt.Errorf("versioning: %s: no transform from v%d to v%d", eventType, from, to)
+		}
+		var err error
+		if data, err = fn(data); err != nil {
+			return nil, fmt.Errorf("versioning: %s: v%d to v%d: %w", eventType, v, next, err)
+		}
+		v = next
+	}
+	return data, nil
+}
+
+// ConvertEvent transforms an encoded event to schema version to.
+// The event's type and version fields select the transforms; a missing version means 1.
+// Other fields of the event are kept, and the result carries the new version.
+func (r *Registry) ConvertEvent(body []byte, to int) ([]byte, error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("versioning: decode event: %w", err)
+	}
+	eventType, from, err := header(event)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return body, nil
+	}
+
+	var data Data
+	dec := json.NewDecoder(bytes.NewReader(event["data"]))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("versioning: decode %s data: %w", eventType, err)
+	}
+	if data, err = r.Convert(eventType, data, from, to); err != nil {
+		return nil, err
+	}
+
+	if event["data"], err = json.Marshal(data); err != nil {
+		return nil, err
+	}
+	if event["version"], err = json.Marshal(to); err != nil {
+		return nil, err
+	}
+	return json.Marshal(event)
+}
+
+// Version returns the type and schema version of an encoded event.
+// A missing version means 1.
+func Version(body []byte) (eventType string, version int, err error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(body, &event); err != nil {
+		return "", 0, fmt.Errorf("versioning: decode event: %w", err)
+	}
+	return header(event)
+}
+
+func header(event map[string]json.RawMessage) (eventType string, version int, err error) {
+	if err := json.Unmarshal(event["type"], &eventType); err != nil {
+		return "", 0, fmt.Errorf("versioning: decode event type: %w", err)
+	}
+	version = 1
+	if raw, ok := event["version"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return "", 0, fmt.Errorf("versioning: decode %s version: %w", eventType, err)
+		}
+	}
+	return eventType, version, nil
+}
@@ -0,0 +1,194 @@
+package versioning
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry returns a registry where user.created is at version 3:
+// v2 splits name into first_name and last_name, and v3 renames email to email_address.
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	if err := r.Register("user.created", 3); err != nil {
+		t.Fatal(err)
+	}
+	r.AddUpcaster("user.created", 1, func(d Data) (Data, error) {
+		name, ok := d["name"].(string)
+		if !ok {
+			return nil, errors.New("name is not a string")
+		}
+		first, last, _ := strings.Cut(name, " ")
+		delete(d, "name")
+		d["first_name"], d["last_name"] = first, last
+		return d, nil
+	})
+	r.AddUpcaster("user.created", 2, func(d Data) (Data, error) {
+		d["email_address"] = d["email"]
+		delete(d, "email")
+		return d, nil
+	})
+	r.AddDowncaster("user.created", 3, func(d Data) (Data, error) {
+		d["email"] = d["email_address"]
+		delete(d, "email_address")
+		return d, nil
+	})
+	r.AddDowncaster("user.created", 2, func(d Data) (Data, error) {
+		d["name"] = strings.TrimSpace(d["first_name"].(string) + " " + d["last_name"].(string))
+		delete(d, "first_name")
+		delete(d, "last_name")
+		return d, nil
+	})
+	return r
+}
+
+var (
+	v1 = Data{"id": "user_1", "email": "user@example.com", "name": "Jane Doe"}
+	v2 = Data{"id": "user_1", "email": "user@example.com", "first_name": "Jane", "last_name": "Doe"}
+	v3 = Data{"id": "user_1", "email_address": "user@example.com", "first_name": "Jane", "last_name": "Doe"}
+)
+
+func TestConvert(t *testing.T) {
+	r := newTestRegistry(t)
+	tests := []struct {
+		name     string
+		data     Data
+		from, to int
+		want     Data
+	}{
+		{name: "upcast", data: v1, from: 1, to: 2, want: v2},
+		{name: "upcast chained", data: v1, from: 1, to: 3, want: v3},
+		{name: "downcast", data: v3, from: 3, to: 2, want: v2},
+		{name: "downcast chained", data: v3, from: 3, to: 1, want: v1},
+		{name: "same version", data: v2, from: 2, to: 2, want: v2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Convert("user.created", maps.Clone(tt.data), tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Convert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertRejects(t *testing.T) {
+	r := newTestRegistry(t)
+	tests := []struct {
+		name      string
+		eventType string
+		data      Data
+		from, to  int
+	}{
+		{name: "unknown version", eventType: "user.created", data: v3, from: 3, to: 4},
+		{name: "from unknown version", eventType: "user.created", data: v3, from: 5, to: 3},
+		{name: "version 0", eventType: "user.created", data: v1, from: 0, to: 1},
+		{name: "unregistered type", eventType: "user.deleted", data: Data{"id": "user_1"}, from: 1, to: 2},
+		{name: "transform error", eventType: "user.created", data: Data{"id": "user_1", "name": 1}, from: 1, to: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := r.Convert(tt.eventType, maps.Clone(tt.data), tt.from, tt.to); err == nil {
+				t.Errorf("Convert() = %v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestConvertEvent(t *testing.T) {
+	r := newTestRegistry(t)
+	// A missing version means 1
+	body := []byte(`{"id":"evt_1","type":"user.created","timestamp":"2025-01-01T12:00:00Z","data":{"id":"user_1","email":"user@example.com","name":"Jane Doe","age":42}}`)
+
+	up, err := r.ConvertEvent(body, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventType, version, err := Version(up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eventType != "user.created" || version != 3 {
+		t.Errorf("converted to %s v%d, want user.created v3", eventType, version)
+	}
+	var event struct {
+		ID        string          `json:"id"`
+		Timestamp string          `json:"timestamp"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(up, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "evt_1" || event.Timestamp != "2025-01-01T12:00:00Z" {
+		t.Errorf("envelope = %s, want the other fields kept", up)
+	}
+	const wantData = `{"age":42,"email_address":"user@example.com","first_name":"Jane","id":"user_1","last_name":"Doe"}`
+	if string(event.Data) != wantData {
+		t.Errorf("data = %s, want %s", event.Data, wantData)
+	}
+
+	down, err := r.ConvertEvent(up, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, version, _ := Version(down); version != 1 {
+		t.Errorf("downcast to v%d, want v1", version)
+	}
+	if err := json.Unmarshal(down, &event); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"age":42,"email":"user@example.com","id":"user_1","name":"Jane Doe"}`; string(event.Data) != want {
+		t.Errorf("data = %s, want %s", event.Data, want)
+	}
+
+	same, err := r.ConvertEvent(body, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(same) != string(body) {
+		t.Errorf("ConvertEvent to the same version = %s, want it unchanged", same)
+	}
+}
+
+func TestConvertEventRejects(t *testing.T) {
+	r := newTestRegistry(t)
+	tests := []struct {
+		name string
+		body string
+		to   int
+	}{
+		{name: "unknown version", body: `{"type":"user.created","version":7,"data":{"id":"user_1"}}`, to: 3},
+		{name: "to unknown version", body: `{"type":"user.created","version":3,"data":{"id":"user_1"}}`, to: 4},
+		{name: "invalid version", body: `{"type":"user.created","version":"2","data":{"id":"user_1"}}`, to: 3},
+		{name: "not an event", body: `[]`, to: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := r.ConvertEvent([]byte(tt.body), tt.to); err == nil {
+				t.Errorf("ConvertEvent() = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("user.created", 0); err == nil {
+		t.Error("Register accepted version 0")
+	}
+	if v := r.Current("user.created"); v != 1 {
+		t.Errorf("Current() of an unregistered type = %d, want 1", v)
+	}
+	if err := r.Register("user.created", 2); err != nil {
+		t.Fatal(err)
+	}
+	if v := r.Current("user.created"); v != 2 {
+		t.Errorf("Current() = %d, want 2", v)
+	}
+}
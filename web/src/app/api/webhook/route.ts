@@ -43,6 +43,16 @@ export async function POST(request: NextRequest) {
 
     console.log(`Received webhook (verified): id=${event.headers.id}`);
 
+    // Echo the challenge of an endpoint.verification event to prove we control this endpoint
+    if (payload.type === "endpoint.verification") {
+      const data = payload.data as { challenge?: string } | undefined;
+      return NextResponse.json({
+        success: true,
+        message: "Endpoint verified",
+        challenge: data?.challenge ?? "",
+      });
+    }
+
     return NextResponse.json({
       success: true,
       message: "Webhook received and verified successfully",